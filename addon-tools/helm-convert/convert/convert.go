@@ -66,7 +66,14 @@ func convertToHelm(o *Options) error {
 		return fmt.Errorf("failed to get filesystem of cluster-compare reference %w", err)
 	}
 
-	templates, helperFuncs, err := getTemplates(cfs, filepath.Base(o.refPath))
+	ref, err := compare.GetReference(cfs, filepath.Base(o.refPath))
+	if err != nil {
+		return fmt.Errorf("failed to get cluster-compare reference  %w", err)
+	}
+	// The files of a reference that extends others are also read from the directories of the references it extends
+	cfs = compare.ReferenceFS(ref, cfs)
+
+	templates, helperFuncs, err := getTemplates(ref, cfs)
 	if err != nil {
 		return err
 	}
@@ -122,11 +129,7 @@ func convertToHelm(o *Options) error {
 	return createChart(helmTemplates, helmValues, o.outputDir, o.chartDescription, o.chartVersion)
 }
 
-func getTemplates(cfs fs.FS, referenceFileName string) ([]compare.ReferenceTemplate, string, error) {
-	ref, err := compare.GetReference(cfs, referenceFileName)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to get cluster-compare reference  %w", err)
-	}
+func getTemplates(ref compare.Reference, cfs fs.FS) ([]compare.ReferenceTemplate, string, error) {
 	templates, err := compare.ParseTemplates(ref, cfs)
 	if err != nil {
 		return templates, "", fmt.Errorf("failed to parse cluster-compare reference templates %w", err)
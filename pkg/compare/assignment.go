@@ -0,0 +1,180 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"math"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// pendingMatch is a CR with a single-instance template among its candidates, its template is only chosen once
+// every CR has been diffed so the single-instance templates can be assigned to the CRs as a whole.
+type pendingMatch struct {
+	clusterCR     *unstructured.Unstructured
+	match         matchCounts
+	userOverrides []*UserOverride
+	// unmatched is set when every single-instance candidate of the CR went to other CRs and it has no other candidate
+	unmatched bool
+}
+
+func hasSingleInstanceCandidate(match matchCounts) bool {
+	for _, m := range match.matches {
+		if m.temp.GetConfig().GetSingleInstance() {
+			return true
+		}
+	}
+	return false
+}
+
+// assignSingleInstanceTemplates chooses the template of every pending CR so that each single-instance template is
+// matched to one CR at most and the total number of differing fields is the lowest possible. A CR keeps the best
+// of its other candidates when it can't get a single-instance template with fewer diffs. CRs left without any template,
+// when there are more CRs than single-instance templates, are set as unmatched.
+func assignSingleInstanceTemplates(pending []pendingMatch) []pendingMatch {
+	if len(pending) == 0 {
+		return pending
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return apiKindNamespaceName(pending[i].clusterCR) < apiKindNamespaceName(pending[j].clusterCR)
+	})
+
+	// Columns are the single-instance templates followed by one column per CR that stands for the CR's best
+	// template that isn't single-instance, or for no template at all
+	columns := make(map[string]int)
+	var singleInstance []string
+	for _, p := range pending {
+		for _, m := range p.match.matches {
+			name := m.temp.GetIdentifier()
+			if _, ok := columns[name]; !ok && m.temp.GetConfig().GetSingleInstance() {
+				columns[name] = len(singleInstance)
+				singleInstance = append(singleInstance, name)
+			}
+		}
+	}
+
+	total := 0
+	for _, p := range pending {
+		for _, m := range p.match.matches {
+			total += m.differingFieldCount()
+		}
+	}
+	// Leaving a CR without a template costs more than any assignment so as many CRs as possible get one
+	unassigned := total + 1
+	forbidden := unassigned * (len(pending) + 1)
+
+	n := len(pending)
+	cost := make([][]int, n)
+	private := make([]*matchCounts, n)
+	for i, p := range pending {
+		cost[i] = make([]int, len(singleInstance)+n)
+		for j := range cost[i] {
+			cost[i][j] = forbidden
+		}
+		cost[i][len(singleInstance)+i] = unassigned
+		for k, m := range p.match.matches {
+			if m.temp.GetConfig().GetSingleInstance() {
+				cost[i][columns[m.temp.GetIdentifier()]] = m.differingFieldCount()
+			} else if private[i] == nil || m.differingFieldCount() < private[i].differingFieldCount() {
+				private[i] = &p.match.matches[k]
+				cost[i][len(singleInstance)+i] = m.differingFieldCount()
+			}
+		}
+	}
+
+	assignment := minCostAssignment(cost)
+	for i := range pending {
+		chosen := pending[i].match
+		column := assignment[i]
+		switch {
+		case column < len(singleInstance):
+			for _, m := range pending[i].match.matches {
+				if m.temp.GetIdentifier() == singleInstance[column] {
+					chosen = m
+				}
+			}
+		case private[i] != nil:
+			chosen = *private[i]
+		default:
+			pending[i].unmatched = true
+			for k := range pending[i].match.candidates {
+				pending[i].match.candidates[k].Selected = false
+			}
+			continue
+		}
+		chosen.matches = pending[i].match.matches
+		chosen.candidates = pending[i].match.candidates
+		for k := range chosen.candidates {
+			chosen.candidates[k].Selected = chosen.candidates[k].Error == "" &&
+				chosen.candidates[k].Template == chosen.temp.GetIdentifier()
+		}
+		pending[i].match = chosen
+	}
+	return pending
+}
+
+// minCostAssignment solves the assignment problem for a cost matrix with no more rows than columns with the
+// Hungarian algorithm, it returns the column assigned to each row.
+func minCostAssignment(cost [][]int) []int {
+	n := len(cost)
+	if n == 0 {
+		return []int{}
+	}
+	m := len(cost[0])
+	// The potentials and the matching are 1-indexed, row 0 and column 0 are used by the algorithm
+	u := make([]int, n+1)
+	v := make([]int, m+1)
+	rowOf := make([]int, m+1)
+	way := make([]int, m+1)
+	for i := 1; i <= n; i++ {
+		rowOf[0] = i
+		j0 := 0
+		minSlack := make([]int, m+1)
+		used := make([]bool, m+1)
+		for j := range minSlack {
+			minSlack[j] = math.MaxInt
+		}
+		for {
+			used[j0] = true
+			i0, delta, j1 := rowOf[j0], math.MaxInt, 0
+			for j := 1; j <= m; j++ {
+				if used[j] {
+					continue
+				}
+				if slack := cost[i0-1][j-1] - u[i0] - v[j]; slack < minSlack[j] {
+					minSlack[j] = slack
+					way[j] = j0
+				}
+				if minSlack[j] < delta {
+					delta = minSlack[j]
+					j1 = j
+				}
+			}
+			for j := 0; j <= m; j++ {
+				if used[j] {
+					u[rowOf[j]] += delta
+					v[j] -= delta
+				} else {
+					minSlack[j] -= delta
+				}
+			}
+			j0 = j1
+			if rowOf[j0] == 0 {
+				break
+			}
+		}
+		for j0 != 0 {
+			j1 := way[j0]
+			rowOf[j0] = rowOf[j1]
+			j0 = j1
+		}
+	}
+	result := make([]int, n)
+	for j := 1; j <= m; j++ {
+		if rowOf[j] != 0 {
+			result[rowOf[j]-1] = j - 1
+		}
+	}
+	return result
+}
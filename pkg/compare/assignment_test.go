@@ -0,0 +1,72 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestMinCostAssignment(t *testing.T) {
+	tests := []struct {
+		name     string
+		cost     [][]int
+		expected []int
+	}{
+		{
+			name:     "empty",
+			cost:     [][]int{},
+			expected: []int{},
+		},
+		{
+			name:     "greedy choice isn't optimal",
+			cost:     [][]int{{0, 3}, {1, 2}},
+			expected: []int{0, 1},
+		},
+		{
+			name:     "square",
+			cost:     [][]int{{4, 1, 3}, {2, 0, 5}, {3, 2, 2}},
+			expected: []int{1, 0, 2},
+		},
+		{
+			name:     "more columns than rows",
+			cost:     [][]int{{7, 1, 9, 9}, {1, 2, 9, 9}},
+			expected: []int{1, 0},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, minCostAssignment(test.cost))
+		})
+	}
+}
+
+func TestAssignSingleInstanceTemplatesLeavesExtraCRsUnmatched(t *testing.T) {
+	temp := ReferenceTemplateV2{
+		Config:              ReferenceTemplateConfigV2{SingleInstance: true},
+		ReferenceTemplateV1: ReferenceTemplateV1{Path: "cm.yaml"},
+	}
+	pendingFor := func(name string, diffs int) pendingMatch {
+		cr := &unstructured.Unstructured{}
+		cr.SetAPIVersion("v1")
+		cr.SetKind("ConfigMap")
+		cr.SetName(name)
+		match := matchCounts{temp: temp, fieldDiffs: make([]FieldDiff, diffs)}
+		match.matches = []matchCounts{match}
+		match.candidates = []CandidateScore{{Template: "cm.yaml", Score: diffs, Selected: true}}
+		return pendingMatch{clusterCR: cr, match: match}
+	}
+
+	pending := assignSingleInstanceTemplates([]pendingMatch{pendingFor("b", 0), pendingFor("a", 2)})
+
+	require.Len(t, pending, 2)
+	assert.Equal(t, "a", pending[0].clusterCR.GetName())
+	assert.True(t, pending[0].unmatched, "the template goes to the CR with fewer diffs")
+	assert.False(t, pending[0].match.candidates[0].Selected)
+	assert.Equal(t, "b", pending[1].clusterCR.GetName())
+	assert.False(t, pending[1].unmatched)
+	assert.Equal(t, "cm.yaml", pending[1].match.temp.GetIdentifier())
+}
@@ -20,16 +20,31 @@ type CapturegroupsInlineDiff struct{}
 type diffInfo struct {
 	dmp   *diffmatchpatch.DiffMatchPatch
 	diffs []diffmatchpatch.Diff
-	caps  map[string][]string
+	caps  captures
+}
+
+// captures holds the values matched by each named capturegroup, in the order they were first matched
+type captures map[string][]string
+
+func (c captures) add(name string, values ...string) {
+	for _, value := range values {
+		if !slices.Contains(c[name], value) {
+			c[name] = append(c[name], value)
+		}
+	}
+}
+
+func (c captures) merge(other captures) {
+	for name, values := range other {
+		c.add(name, values...)
+	}
 }
 
 func (id *diffInfo) addCapture(name, value string) {
 	if id.caps == nil {
-		id.caps = make(map[string][]string)
-	}
-	if !slices.Contains(id.caps[name], value) {
-		id.caps[name] = append(id.caps[name], value)
+		id.caps = make(captures)
 	}
+	id.caps.add(name, value)
 }
 
 type CgInfo struct {
@@ -248,7 +263,11 @@ func (id CapturegroupsInlineDiff) Diff(pattern, value string) string {
 	// General approach:
 	//  - Match all relevant capturegroups
 	//  - Substitute in the values for all matched capturegroups to the pattern
+	return id.DiffWithCaptures(pattern, value, id.Capture(pattern, value))
+}
 
+// Capture returns the values matched by the capturegroups of the pattern
+func (id CapturegroupsInlineDiff) Capture(pattern, value string) captures {
 	cgDiff := diffInfo{}
 
 	// Doing a word-wise diff shrinks the probleset by avoiding any text that
@@ -274,26 +293,35 @@ func (id CapturegroupsInlineDiff) Diff(pattern, value string) string {
 			}
 		}
 	}
+	return cgDiff.caps
+}
 
+// DiffWithCaptures substitutes the captured values into the pattern. The captures may come from
+// other fields than this one, so a group must match the same value everywhere it is used.
+func (id CapturegroupsInlineDiff) DiffWithCaptures(pattern, _ string, caps captures) string {
 	// Copy the original pattern string from the template, interpolating in the
-	// first matched value from the captures above. This will cause the
+	// first matched value from the captures. This will cause the
 	// higher-level diff to show:
 	// - missed matches as different
 	// - proper matches as identical
 	// - any different values matched to the same-named capturegroups as different
 	reconciledString := ""
 	idx := 0
+	used := make([]string, 0)
 	for _, group := range CapturegroupIndex(pattern) {
 		if idx < group.Start {
 			reconciledString += pattern[idx:group.Start]
 		}
-		if matches, ok := cgDiff.caps[group.Name]; ok {
+		if matches, ok := caps[group.Name]; ok {
 			if len(matches) == 1 {
 				reconciledString += matches[0]
 			} else {
 				// Multiple matches detected, so call attention to them
 				reconciledString += fmt.Sprintf("(?<%s>=%s)", group.Name, matches[0])
 			}
+			if !slices.Contains(used, group.Name) {
+				used = append(used, group.Name)
+			}
 		} else {
 			reconciledString += pattern[group.Start:group.End]
 		}
@@ -303,10 +331,11 @@ func (id CapturegroupsInlineDiff) Diff(pattern, value string) string {
 		reconciledString += pattern[idx:]
 	}
 
-	// And for clarity, highlight any capturegroups that had different values
-	// matched at different points
-	for cgName, cgValues := range cgDiff.caps {
-		if len(cgValues) > 1 {
+	// And for clarity, highlight any capturegroups of this pattern that had
+	// different values matched at different points
+	slices.Sort(used)
+	for _, cgName := range used {
+		if cgValues := caps[cgName]; len(cgValues) > 1 {
 			reconciledString += fmt.Sprintf("\nWARNING: Capturegroup (?<%s>…) matched multiple values: « %s »", cgName, strings.Join(cgValues, " | "))
 		}
 	}
@@ -228,3 +228,17 @@ func TestCapturegroupsDiff(t *testing.T) {
 		})
 	}
 }
+
+func TestCapturegroupsDiffWithCaptures(t *testing.T) {
+	id := CapturegroupsInlineDiff{}
+	caps := make(captures)
+	caps.merge(id.Capture("name: (?<cluster>[a-z0-9]+)", "name: spoke1"))
+	caps.merge(id.Capture("url: api.(?<cluster>[a-z0-9]+).(?<domain>[a-z.]+)", "url: api.spoke2.example.com"))
+	assert.Equal(t, captures{"cluster": {"spoke1", "spoke2"}, "domain": {"example.com"}}, caps)
+
+	assert.Equal(t,
+		"name: (?<cluster>=spoke1)\nWARNING: Capturegroup (?<cluster>…) matched multiple values: « spoke1 | spoke2 »",
+		id.DiffWithCaptures("name: (?<cluster>[a-z0-9]+)", "name: spoke1", caps))
+	assert.Equal(t, "domain: example.com", id.DiffWithCaptures("domain: (?<domain>[a-z.]+)", "domain: example.com", caps),
+		"warnings are only added for the groups used in the field")
+}
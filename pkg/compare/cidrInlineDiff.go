@@ -0,0 +1,65 @@
+package compare
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+const (
+	cidrContains inlineDiffType = "cidrContains"
+	ipInCIDR     inlineDiffType = "ipInCIDR"
+)
+
+// CIDRContainsInlineDiff accepts any CIDR that is within the CIDR in the template,
+// for example 10.1.0.0/16 when the template is 10.0.0.0/8.
+type CIDRContainsInlineDiff struct{}
+
+func (id CIDRContainsInlineDiff) Diff(templateValue, crValue string) string {
+	outer, err := netip.ParsePrefix(strings.TrimSpace(templateValue))
+	if err != nil {
+		return templateValue
+	}
+	inner, err := netip.ParsePrefix(strings.TrimSpace(crValue))
+	if err != nil {
+		return templateValue
+	}
+	if outer.Bits() <= inner.Bits() && outer.Contains(inner.Addr()) {
+		return crValue
+	}
+	return templateValue
+}
+
+func (id CIDRContainsInlineDiff) Validate(templateValue string) error {
+	_, err := netip.ParsePrefix(strings.TrimSpace(templateValue))
+	if err != nil {
+		return fmt.Errorf("invalid CIDR passed to inline cidrContains diff function: %w", err)
+	}
+	return nil
+}
+
+// IPInCIDRInlineDiff accepts any IP address that is within the CIDR in the template.
+type IPInCIDRInlineDiff struct{}
+
+func (id IPInCIDRInlineDiff) Diff(templateValue, crValue string) string {
+	prefix, err := netip.ParsePrefix(strings.TrimSpace(templateValue))
+	if err != nil {
+		return templateValue
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(crValue))
+	if err != nil {
+		return templateValue
+	}
+	if prefix.Contains(addr) {
+		return crValue
+	}
+	return templateValue
+}
+
+func (id IPInCIDRInlineDiff) Validate(templateValue string) error {
+	_, err := netip.ParsePrefix(strings.TrimSpace(templateValue))
+	if err != nil {
+		return fmt.Errorf("invalid CIDR passed to inline ipInCIDR diff function: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCIDRContainsInlineDiff(t *testing.T) {
+	tests := []struct {
+		template string
+		cr       string
+		expected string
+	}{
+		{"10.0.0.0/8", "10.1.0.0/16", "10.1.0.0/16"},
+		{"10.0.0.0/8", "10.0.0.0/8", "10.0.0.0/8"},
+		{"10.0.0.0/16", "10.0.0.0/8", "10.0.0.0/16"},
+		{"10.0.0.0/8", "192.168.0.0/16", "10.0.0.0/8"},
+		{"fd00::/8", "fd01:2::/64", "fd01:2::/64"},
+		{"10.0.0.0/8", "10.1.0.1", "10.0.0.0/8"},
+	}
+	for _, test := range tests {
+		t.Run(test.template+" "+test.cr, func(t *testing.T) {
+			assert.Equal(t, test.expected, CIDRContainsInlineDiff{}.Diff(test.template, test.cr))
+		})
+	}
+	assert.NoError(t, CIDRContainsInlineDiff{}.Validate("10.0.0.0/8"))
+	assert.Error(t, CIDRContainsInlineDiff{}.Validate("10.0.0.0"))
+}
+
+func TestIPInCIDRInlineDiff(t *testing.T) {
+	tests := []struct {
+		template string
+		cr       string
+		expected string
+	}{
+		{"192.168.111.0/24", "192.168.111.5", "192.168.111.5"},
+		{"192.168.111.0/24", "192.168.112.5", "192.168.111.0/24"},
+		{"fd00::/8", "fd00::1", "fd00::1"},
+		{"192.168.111.0/24", "192.168.111.0/28", "192.168.111.0/24"},
+	}
+	for _, test := range tests {
+		t.Run(test.template+" "+test.cr, func(t *testing.T) {
+			assert.Equal(t, test.expected, IPInCIDRInlineDiff{}.Diff(test.template, test.cr))
+		})
+	}
+	assert.NoError(t, IPInCIDRInlineDiff{}.Validate("fd00::/8"))
+	assert.Error(t, IPInCIDRInlineDiff{}.Validate("192.168.111.5"))
+}
@@ -8,18 +8,22 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
 	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
-	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/gosimple/slug"
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/cli-runtime/pkg/genericiooptions"
 	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/discovery"
@@ -60,13 +64,13 @@ var (
 		apiVersion_kind_namespace_name: <Template File Name>. For resources that don't have a namespace the matches can
 		be added  as pairs of apiVersion_kind_name: <Template File Name>.
 
+		By default, the diff is computed by a built-in diff engine that produces the same output as running
+		the "diff" command with the "-u" (unified diff) and "-N" (treat absent files as empty) options.
+
 		KUBECTL_EXTERNAL_DIFF environment variable can be used to select your own diff
-		command. Users can use external commands with params too, example:
+		command instead of the built-in one. Users can use external commands with params too, example:
 		KUBECTL_EXTERNAL_DIFF="colordiff -N -u"
 
-		By default, the "diff" command available in your path will be run with the "-u"
-		(unified diff) and "-N" (treat absent files as empty) options.
-
 		Exit status: 0 No differences were found. 1 Differences were found. >1 kubectl
 		or diff failed with an error.
 
@@ -101,37 +105,68 @@ const (
 	DiffsFoundMsg           = "there are differences between the cluster CRs and the reference CRs"
 	noTemplateForGeneration = "Requested user override generation but no entires for which template to generate overrides for"
 	noReason                = "Reason required when generating overrides"
+	patternMatchedNothing   = "Correlation pattern %s didn't match any of the input CRs"
 )
 
 const (
 	Json      string = "json"
 	Yaml      string = "yaml"
 	PatchYaml string = "generate-patches"
+	Explain   string = "explain"
 )
 
-var OutputFormats = []string{Json, Yaml, PatchYaml}
+var OutputFormats = []string{Json, Yaml, PatchYaml, Explain}
 
 type Options struct {
 	CRs                resource.FilenameOptions
 	referenceConfig    string
 	diffConfigFileName string
+	valuesFile         string
 	diffAll            bool
 	verboseOutput      bool
 	ShowManagedFields  bool
 	OutputFormat       string
+	explain            bool
+	numSuggestions     int
 
 	builder        *resource.Builder
 	correlator     *MultiCorrelator[ReferenceTemplate]
 	metricsTracker *MetricsTracker
 	templates      []ReferenceTemplate
-	local          bool
-	types          []string
-	ref            Reference
-	userConfig     UserConfig
-	Concurrency    int
-
-	userOverridesPath               string
-	userOverridesCorrelator         Correlator[*UserOverride]
+	values         map[string]any
+	// reference is the loaded reference the templates, the values and the field group correlators are taken from,
+	// it's read by Complete unless it's already loaded
+	reference    *loadedReference
+	local        bool
+	types        []string
+	ref          Reference
+	userConfig   UserConfig
+	Concurrency  int
+	externalDiff bool
+	// errOutLock serializes the writes of the external diffs run concurrently to ErrOut
+	errOutLock sync.Mutex
+
+	// globalCaptures holds the resolved values of the reference's global capture groups, they're resolved once every
+	// CR is matched
+	globalCaptures     captures
+	globalCapturesLock sync.Mutex
+
+	unmatchedExplanations     []UnmatchedExplanation
+	unmatchedExplanationsLock sync.Mutex
+
+	// pendingMatches are the CRs with single-instance template candidates, they are matched after all CRs are diffed
+	pendingMatches     []pendingMatch
+	pendingMatchesLock sync.Mutex
+	// ownedMatches are the CRs with candidate templates that expect an owner, they are matched after all other CRs
+	ownedMatches     []ownedMatch
+	ownedMatchesLock sync.Mutex
+	matchedOwners    matchedOwners
+
+	userOverridesPath       string
+	userOverridesCorrelator Correlator[*UserOverride]
+	patternCorrelators      []unmatchedPatternsReporter
+	// manualPatternCorrelator matches the CRs to the templates of the pattern correlation pairs of the diff config
+	manualPatternCorrelator         *PatternCorrelator[ReferenceTemplate]
 	userOverrides                   []*UserOverride
 	newUserOverrides                []*UserOverride
 	templatesToGenerateOverridesFor []string
@@ -143,19 +178,13 @@ type Options struct {
 
 func NewCmd(f kcmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
 	options := NewOptions(streams)
-	example := compareExample
-	if strings.HasPrefix(filepath.Base(os.Args[0]), "oc-") {
-		example = strings.ReplaceAll(compareExample, "kubectl", "oc")
-	} else if !strings.HasPrefix(filepath.Base(os.Args[0]), "kubectl-") {
-		example = strings.ReplaceAll(compareExample, "kubectl ", "")
-	}
 
 	cmd := &cobra.Command{
 		Use:                   "compare -r <Reference File>",
 		DisableFlagsInUseLine: true,
 		Short:                 i18n.T("Compare a reference configuration and a set of cluster configuration CRs."),
 		Long:                  compareLong,
-		Example:               example,
+		Example:               commandExample(compareExample),
 		Run: func(cmd *cobra.Command, args []string) {
 			kcmdutil.CheckDiffErr(options.Complete(f, cmd, args))
 			// `kubectl cluster-compare` propagates the error code from
@@ -187,11 +216,18 @@ func NewCmd(f kcmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Comma
 	kcmdutil.AddFilenameOptionFlags(cmd, &options.CRs, "contains the configuration to diff")
 	cmd.Flags().StringVarP(&options.diffConfigFileName, "diff-config", "c", "", "Path to the user config file")
 	cmd.Flags().StringVarP(&options.referenceConfig, "reference", "r", "", "Path to reference config file.")
+	cmd.Flags().StringVar(&options.valuesFile, "values", "",
+		"Path to a YAML file with values for the parameters of the reference, templates get them under .Values")
 	cmd.Flags().BoolVar(&options.ShowManagedFields, "show-managed-fields", options.ShowManagedFields, "If true, include managed fields in the diff.")
 	cmd.Flags().BoolVarP(&options.diffAll, "all-resources", "A", options.diffAll,
 		"If present, In live mode will try to match all resources that are from the types mentioned in the reference. "+
 			"In local mode will try to match all resources passed to the command")
 	cmd.Flags().BoolVarP(&options.verboseOutput, "verbose", "v", options.verboseOutput, "Increases the verbosity of the tool")
+	cmd.Flags().IntVar(&options.numSuggestions, "suggestions", 3,
+		"Number of templates suggested for each cluster CR that is unmatched to reference CRs, 0 disables the suggestions")
+	cmd.Flags().BoolVar(&options.explain, "explain", options.explain,
+		"If present, explains for each CR which correlator matched it to a template, the keys it hashed to and the score of "+
+			"every candidate template. For CRs that weren't matched the closest templates are listed")
 
 	cmd.Flags().StringVarP(&options.userOverridesPath, "overrides", "p", "", "Path to user overrides")
 	cmd.Flags().StringSliceVar(&options.templatesToGenerateOverridesFor, "generate-override-for", []string{}, "Path for template file you wish to generate a override for")
@@ -211,9 +247,22 @@ func NewCmd(f kcmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Comma
 		},
 	))
 
+	cmd.AddCommand(NewLintCmd(f, streams))
+	cmd.AddCommand(NewTestReferenceCmd(f, streams))
+
 	return cmd
 }
 
+// commandExample adapts the example to the name the tool is invoked with, as a kubectl or oc plugin or by itself
+func commandExample(example string) string {
+	if strings.HasPrefix(filepath.Base(os.Args[0]), "oc-") {
+		return strings.ReplaceAll(example, "kubectl", "oc")
+	} else if !strings.HasPrefix(filepath.Base(os.Args[0]), "kubectl-") {
+		return strings.ReplaceAll(example, "kubectl ", "")
+	}
+	return example
+}
+
 func NewOptions(ioStreams genericiooptions.IOStreams) *Options {
 	return &Options{
 		IOStreams: ioStreams,
@@ -245,11 +294,15 @@ func GetRefFS(refConfig string) (fs.FS, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get absolute path: %w", err)
 	}
-	return os.DirFS(rootPath), nil
+	return newDirFS(rootPath), nil
 }
 func (o *Options) Complete(f kcmdutil.Factory, cmd *cobra.Command, args []string) error {
 	var err error
 	o.builder = f.NewBuilder()
+	o.externalDiff = os.Getenv("KUBECTL_EXTERNAL_DIFF") != ""
+	if o.OutputFormat == Explain {
+		o.explain = true
+	}
 
 	if o.OutputFormat == PatchYaml {
 		if len(o.templatesToGenerateOverridesFor) == 0 {
@@ -264,20 +317,13 @@ func (o *Options) Complete(f kcmdutil.Factory, cmd *cobra.Command, args []string
 	if o.referenceConfig == "" {
 		return kcmdutil.UsageErrorf(cmd, noRefFileWasPassed)
 	}
-	if _, err := os.Stat(o.referenceConfig); os.IsNotExist(err) && !isURL(o.referenceConfig) {
-		return fmt.Errorf(refFileNotExistsError)
-	}
-
-	cfs, err := GetRefFS(o.referenceConfig)
-	if err != nil {
-		return err
-	}
-
-	referenceFileName := filepath.Base(o.referenceConfig)
-	o.ref, err = GetReference(cfs, referenceFileName)
-	if err != nil {
-		return err
+	if o.reference == nil {
+		o.reference, err = loadReference(o.referenceConfig, o.valuesFile)
+		if err != nil {
+			return err
+		}
 	}
+	o.ref, o.values, o.templates = o.reference.ref, o.reference.values, o.reference.templates
 
 	if o.diffConfigFileName != "" {
 		o.userConfig, err = parseDiffConfig(o.diffConfigFileName)
@@ -285,10 +331,6 @@ func (o *Options) Complete(f kcmdutil.Factory, cmd *cobra.Command, args []string
 			return err
 		}
 	}
-	o.templates, err = ParseTemplates(o.ref, cfs)
-	if err != nil {
-		return err
-	}
 
 	if o.userOverridesPath != "" {
 		o.userOverrides, err = LoadUserOverrides(o.userOverridesPath)
@@ -322,6 +364,48 @@ func (o *Options) Complete(f kcmdutil.Factory, cmd *cobra.Command, args []string
 	return o.setLiveSearchTypes(f)
 }
 
+// loadedReference is a reference with its templates parsed and its field group correlators set up, it's shared by
+// the comparisons of the test cases of test-reference so the reference is only read and parsed once
+type loadedReference struct {
+	ref              Reference
+	values           map[string]any
+	templates        []ReferenceTemplate
+	groupCorrelators []Correlator[ReferenceTemplate]
+}
+
+// loadReference reads the reference and parses its templates with the values of the values file, the metadata of the
+// templates the CRs are correlated by is extracted with the values
+func loadReference(referenceConfig, valuesFile string) (*loadedReference, error) {
+	if _, err := os.Stat(referenceConfig); os.IsNotExist(err) && !isURL(referenceConfig) {
+		return nil, fmt.Errorf(refFileNotExistsError)
+	}
+	cfs, err := GetRefFS(referenceConfig)
+	if err != nil {
+		return nil, err
+	}
+	result := &loadedReference{}
+	result.ref, err = GetReference(cfs, filepath.Base(referenceConfig))
+	if err != nil {
+		return nil, err
+	}
+	result.values, err = LoadValues(result.ref, valuesFile)
+	if err != nil {
+		return nil, err
+	}
+	result.templates, err = ParseTemplatesWithValues(result.ref, cfs, result.values)
+	if err != nil {
+		return nil, err
+	}
+	for _, correlation := range result.ref.GetFieldGroupCorrelations() {
+		groupCorrelator, err := NewGroupCorrelator(correlation.FieldGroups, correlation.Templates)
+		if err != nil {
+			return nil, err
+		}
+		result.groupCorrelators = append(result.groupCorrelators, groupCorrelator)
+	}
+	return result, nil
+}
+
 // These fields are used by the GroupCorrelator who attempts to match templates based on the following priority order:
 // apiVersion_name_namespace_kind. If no single match is found, it proceeds to trying matching by apiVersion_name_kind,
 // then namespace_kind, and finally kind alone.
@@ -344,26 +428,44 @@ var defaultFieldGroups = [][][]string{
 // This function configures the following base correlators:
 //  1. ExactMatchCorrelator - Matches CRs based on pairs specifying, for each cluster CR, its matching template.
 //     The pairs are read from the diff config and provided to the correlator.
-//  2. GroupCorrelator - Matches CRs based on groups of fields that are similar in cluster resources and templates.
+//  2. PatternCorrelator - Matches CRs based on pairs where the cluster CR is given as a regex or glob pattern.
+//  3. SelectorCorrelator - Matches CRs based on label and annotation selectors mapped to templates in the diff config.
+//  4. GroupCorrelator - Matches CRs based on groups of fields that are similar in cluster resources and templates.
+//     One is created for each set of field groups of the reference when it's loaded, the groups declared by the
+//     reference come first.
 //
 // The base correlators are combined using a MultiCorrelator, which attempts to match a template for each base correlator
 // in the specified sequence.
 func (o *Options) setupCorrelators() error {
 	var correlators []Correlator[ReferenceTemplate]
-	if len(o.userConfig.CorrelationSettings.ManualCorrelation.CorrelationPairs) > 0 {
-		manualCorrelator, err := NewExactMatchCorrelator(o.userConfig.CorrelationSettings.ManualCorrelation.CorrelationPairs, o.templates)
+	exactPairs, patternPairs := splitCorrelationPairs(o.userConfig.CorrelationSettings.ManualCorrelation.CorrelationPairs)
+	if len(exactPairs) > 0 {
+		manualCorrelator, err := NewExactMatchCorrelator(exactPairs, o.templates)
 		if err != nil {
 			return err
 		}
 		correlators = append(correlators, manualCorrelator)
 	}
 
-	groupCorrelator, err := NewGroupCorrelator(defaultFieldGroups, o.templates)
-	if err != nil {
-		return err
+	if len(patternPairs) > 0 {
+		patternCorrelator, err := NewPatternCorrelator(patternPairs, o.templates)
+		if err != nil {
+			return err
+		}
+		correlators = append(correlators, patternCorrelator)
+		o.patternCorrelators = append(o.patternCorrelators, patternCorrelator)
+		o.manualPatternCorrelator = patternCorrelator
 	}
 
-	correlators = append(correlators, groupCorrelator)
+	if len(o.userConfig.CorrelationSettings.SelectorCorrelation.CorrelationSelectors) > 0 {
+		selectorCorrelator, err := NewSelectorCorrelator(o.userConfig.CorrelationSettings.SelectorCorrelation.CorrelationSelectors, o.templates)
+		if err != nil {
+			return err
+		}
+		correlators = append(correlators, selectorCorrelator)
+	}
+
+	correlators = append(correlators, o.reference.groupCorrelators...)
 
 	o.correlator = NewMultiCorrelator(correlators)
 	o.metricsTracker = NewMetricsTracker()
@@ -371,21 +473,30 @@ func (o *Options) setupCorrelators() error {
 }
 
 func (o *Options) setupOverrideCorrelators() error {
-	extactOverrideMatches := make(map[string]string)
+	overrideMatches := make(map[string]string)
 	for _, uo := range o.userOverrides {
 		if uo.ExactMatch != "" {
-			extactOverrideMatches[uo.ExactMatch] = uo.GetIdentifier()
+			overrideMatches[uo.ExactMatch] = uo.GetIdentifier()
 		}
 	}
 
 	correlators := make([]Correlator[*UserOverride], 0)
-	if len(extactOverrideMatches) > 0 {
-		manualOverrideCorrelator, err := NewExactMatchCorrelator(extactOverrideMatches, o.userOverrides)
+	exactOverrideMatches, patternOverrideMatches := splitCorrelationPairs(overrideMatches)
+	if len(exactOverrideMatches) > 0 {
+		manualOverrideCorrelator, err := NewExactMatchCorrelator(exactOverrideMatches, o.userOverrides)
 		if err != nil {
 			return err
 		}
 		correlators = append(correlators, manualOverrideCorrelator)
 	}
+	if len(patternOverrideMatches) > 0 {
+		patternOverrideCorrelator, err := NewPatternCorrelator(patternOverrideMatches, o.userOverrides)
+		if err != nil {
+			return err
+		}
+		correlators = append(correlators, patternOverrideCorrelator)
+		o.patternCorrelators = append(o.patternCorrelators, patternOverrideCorrelator)
+	}
 
 	groupCorrelator, err := NewGroupCorrelator(defaultFieldGroups, o.userOverrides)
 	if err != nil {
@@ -507,70 +618,142 @@ func extractPath(str string, pathIndex int) string {
 	return "Unknown Path"
 }
 
-type matchCounts struct {
-	diffOutput   *bytes.Buffer
-	userOverride *UserOverride
-	temp         ReferenceTemplate
+// knownGlobalCaptures returns a copy of the resolved values of the global capture groups
+func (o *Options) knownGlobalCaptures() captures {
+	o.globalCapturesLock.Lock()
+	defer o.globalCapturesLock.Unlock()
+	result := make(captures, len(o.globalCaptures))
+	for name, values := range o.globalCaptures {
+		result[name] = slices.Clone(values)
+	}
+	return result
+}
 
-	leafCount    *int
-	newlineCount int
+// resolveGlobalCaptures sets every global capture group to the value captured by the most CRs, ties are broken by the
+// lowest value. The values are resolved once all CRs are matched so they don't depend on the order of the CRs.
+func (o *Options) resolveGlobalCaptures(matched []pendingMatch) {
+	if o.ref == nil {
+		return
+	}
+	resolved := make(captures)
+	for _, name := range o.ref.GetGlobalCaptureGroups() {
+		counts := make(map[string]int)
+		for _, m := range matched {
+			for _, value := range m.match.captures[name] {
+				counts[value]++
+			}
+		}
+		best := ""
+		for value, count := range counts {
+			if best == "" || count > counts[best] || count == counts[best] && value < best {
+				best = value
+			}
+		}
+		if best != "" {
+			resolved[name] = []string{best}
+		}
+	}
+	o.globalCapturesLock.Lock()
+	defer o.globalCapturesLock.Unlock()
+	o.globalCaptures = resolved
 }
 
-func countNewlines(diffOutput *bytes.Buffer) int {
-	return bytes.Count(diffOutput.Bytes(), []byte("\n"))
+// capturesOtherGlobalValues reports whether the CR captured a global capture group with a value other than its
+// resolved value
+func capturesOtherGlobalValues(caps, resolved captures) bool {
+	for name, known := range resolved {
+		for _, value := range caps[name] {
+			if !slices.Contains(known, value) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-func countLeaf(d any) int {
-	count := 0
-	switch t := d.(type) {
-	case map[string]any:
-		for _, v := range t {
-			count += countLeaf(v)
+// recheckGlobalCaptures diffs the CRs that captured other values than the resolved values of the global capture
+// groups again, now against the resolved values, so the groups that don't match are reported in their diff.
+func (o *Options) recheckGlobalCaptures(matched []pendingMatch) error {
+	o.resolveGlobalCaptures(matched)
+	resolved := o.knownGlobalCaptures()
+	var errs []error
+	for i, m := range matched {
+		if !capturesOtherGlobalValues(m.match.captures, resolved) {
+			continue
 		}
-	case []any:
-		for _, v := range t {
-			count += countLeaf(v)
+		rechecked, err := getBestMatchByLines([]ReferenceTemplate{m.match.temp}, m.clusterCR, m.userOverrides, o)
+		if err != nil {
+			errs = append(errs, err)
+			continue
 		}
-	default:
-		return 1
+		rechecked.candidates, rechecked.matches, rechecked.owner = m.match.candidates, m.match.matches, m.match.owner
+		matched[i].match = rechecked
+	}
+	return errors.Join(errs...)
+}
+
+// manuallyCorrelated returns whether the CR is correlated to a template by its name or by a pattern in the manual
+// correlation pairs of the diff config
+func (o *Options) manuallyCorrelated(clusterCR *unstructured.Unstructured) bool {
+	if _, ok := o.userConfig.CorrelationSettings.ManualCorrelation.CorrelationPairs[apiKindNamespaceName(clusterCR)]; ok {
+		return true
 	}
-	return count
+	return o.manualPatternCorrelator != nil && o.manualPatternCorrelator.matches(clusterCR)
 }
 
-func countLeaves(uo *UserOverride) *int {
-	var data map[string]any
-	err := json.Unmarshal([]byte(uo.Patch), &data)
-	if err != nil {
-		return nil
+// explainUnmatched records why a CR wasn't correlated to a template when running with --explain
+func (o *Options) explainUnmatched(clusterCR *unstructured.Unstructured, candidates []CandidateScore) {
+	if !o.explain {
+		return
 	}
-	count := countLeaf(data)
-	return &count
+	explanation := o.correlator.Explain(clusterCR)
+	explanation.Candidates = candidates
+	o.unmatchedExplanationsLock.Lock()
+	defer o.unmatchedExplanationsLock.Unlock()
+	o.unmatchedExplanations = append(o.unmatchedExplanations, UnmatchedExplanation{
+		CRName:      apiKindNamespaceName(clusterCR),
+		Explanation: explanation,
+	})
+}
+
+type matchCounts struct {
+	diffOutput   *bytes.Buffer
+	userOverride *UserOverride
+	temp         ReferenceTemplate
+	fieldDiffs   []FieldDiff
+	captures     captures
+	candidates   []CandidateScore
+	owner        string
+	// matches holds every template the CR was successfully diffed against, the match was chosen from them
+	matches []matchCounts
 }
 
+// differingFieldCount returns the number of leaf fields that differ between the CR and the template
+func (m matchCounts) differingFieldCount() int {
+	return countDifferingFields(m.fieldDiffs)
+}
+
+// findBestMatch returns the match with the least amount of differing fields,
+// in case of a tie the first of the matches is returned.
 func findBestMatch(matches []matchCounts) matchCounts {
-	var bestNewlineMatch *matchCounts
-	var bestLeafMatch *matchCounts
-	useNewlineMatch := false
-	for _, match := range matches {
-		if bestNewlineMatch == nil || match.newlineCount < bestNewlineMatch.newlineCount {
-			bestNewlineMatch = &match
-		}
-		if match.leafCount == nil {
-			useNewlineMatch = true
-		}
-		if !useNewlineMatch && (bestLeafMatch == nil || (*match.leafCount < *bestLeafMatch.leafCount)) {
-			bestLeafMatch = &match
+	var bestMatch *matchCounts
+	for i, match := range matches {
+		if bestMatch == nil || match.differingFieldCount() < bestMatch.differingFieldCount() {
+			bestMatch = &matches[i]
 		}
 	}
-	if useNewlineMatch {
-		return *bestNewlineMatch
+	if bestMatch == nil {
+		return matchCounts{}
 	}
-	return *bestLeafMatch
-
+	return *bestMatch
 }
-func getBestMatchByLines(templates []ReferenceTemplate, cr *unstructured.Unstructured, userOverrides []*UserOverride, o *Options) (ReferenceTemplate, *bytes.Buffer, *UserOverride, error) {
+
+func getBestMatchByLines(templates []ReferenceTemplate, cr *unstructured.Unstructured, userOverrides []*UserOverride, o *Options) (matchCounts, error) {
 	matches := make([]matchCounts, 0)
 	errs := make([]error, 0)
+	failed := make(map[string]error)
+	// A template that accesses fields the CR doesn't have only fails the CR when no other template can be compared to it
+	missingKeyErrs := make([]error, 0)
 
 	for _, temp := range templates {
 		templateOverrides := make([]*UserOverride, 0)
@@ -580,33 +763,47 @@ func getBestMatchByLines(templates []ReferenceTemplate, cr *unstructured.Unstruc
 			}
 		}
 
-		diffOutput, infoObj, err := diffAgainstTemplate(temp, cr, templateOverrides, o)
+		diffOutput, fieldDiffs, infoObj, err := diffAgainstTemplate(temp, cr, templateOverrides, o)
 		if err != nil {
-			errs = append(errs, err)
+			if errors.As(err, &MissingKeyError{}) {
+				missingKeyErrs = append(missingKeyErrs, err)
+			} else {
+				errs = append(errs, err)
+			}
+			failed[temp.GetIdentifier()] = err
 			continue
 		}
 		uo, err := CreateMergePatch(temp, infoObj, o.overrideReason)
-		var leafCount *int
-		// if user override is ok we can count the leaves in the patches
-		if err == nil {
-			leafCount = countLeaves(uo)
+		if err != nil {
+			uo = nil
 		}
 		matches = append(matches, matchCounts{
 			diffOutput:   diffOutput,
 			temp:         temp,
 			userOverride: uo,
-			newlineCount: countNewlines(diffOutput),
-			leafCount:    leafCount,
+			fieldDiffs:   fieldDiffs,
+			captures:     infoObj.capturedValues,
 		})
 	}
-	bestMatch := findBestMatch(matches)
-	return bestMatch.temp, bestMatch.diffOutput, bestMatch.userOverride, errors.Join(errs...)
+	if len(matches) == 0 {
+		errs = append(errs, missingKeyErrs...)
+	}
+	best := findBestMatch(matches)
+	best.candidates = candidateScores(matches, best, failed)
+	best.matches = matches
+	return best, errors.Join(errs...)
 }
 
-func diffAgainstTemplate(temp ReferenceTemplate, clusterCR *unstructured.Unstructured, userOverrides []*UserOverride, o *Options) (*bytes.Buffer, *InfoObject, error) {
-	localRef, err := temp.Exec(clusterCR.Object)
+func diffAgainstTemplate(temp ReferenceTemplate, clusterCR *unstructured.Unstructured, userOverrides []*UserOverride, o *Options) (*bytes.Buffer, []FieldDiff, *InfoObject, error) {
+	localRef, err := temp.Exec(templateData(clusterCR.Object, o.values))
 	if err != nil {
-		return nil, nil, err //nolint: wrapcheck
+		return nil, nil, nil, err //nolint: wrapcheck
+	}
+	documents := newPathMatcher(temp.GetConfig().GetEmbeddedDocuments())
+	if len(documents) > 0 {
+		// The cluster CR is still used to render the other templates so only its copy holds the parsed documents
+		clusterCR = clusterCR.DeepCopy()
+		decodeEmbeddedDocuments(clusterCR.Object, documents)
 	}
 	obj := InfoObject{
 		injectedObjFromTemplate: localRef,
@@ -615,31 +812,69 @@ func diffAgainstTemplate(temp ReferenceTemplate, clusterCR *unstructured.Unstruc
 		allowMerge:              temp.GetConfig().GetAllowMerge(),
 		userOverrides:           userOverrides,
 		templateFieldConf:       temp.GetConfig().GetInlineDiffFuncs(),
+		listKeys:                newPathMatcher(temp.GetConfig().GetListMergeKeys()),
+		unorderedLists:          newPathMatcher(temp.GetConfig().GetListTypes()),
+		embeddedDocuments:       documents,
+		capturedValues:          make(captures),
+		globalCaptures:          o.knownGlobalCaptures(),
 	}
 
-	differ, err := diff.NewDiffer("MERGED", "LIVE")
 	diffOutput := new(bytes.Buffer)
+	merged, live, err := obj.diffableObjects(o.ShowManagedFields)
 	if err != nil {
-		return diffOutput, &obj, fmt.Errorf("failed to create diff instance: %w", err)
+		return diffOutput, nil, &obj, fmt.Errorf("error occurered during diff: %w", err)
 	}
-	defer differ.TearDown()
+	fieldDiffs := diffFields(nil, merged.Object, live.Object, obj.listKeys)
+
+	if o.externalDiff {
+		err = runExternalDiff(obj.Name(), merged, live, diffOutput, o)
+		return diffOutput, fieldDiffs, &obj, err
+	}
+
+	var from, to bytes.Buffer
+	printer := diff.Printer{}
+	if err := printer.Print(merged, &from); err != nil {
+		return diffOutput, fieldDiffs, &obj, fmt.Errorf("error occurered during diff: %w", err)
+	}
+	if err := printer.Print(live, &to); err != nil {
+		return diffOutput, fieldDiffs, &obj, fmt.Errorf("error occurered during diff: %w", err)
+	}
+	diffOutput.WriteString(unifiedDiff(path.Join("MERGED", obj.Name()), path.Join("LIVE", obj.Name()), from.Bytes(), to.Bytes(), time.Now()))
+	return diffOutput, fieldDiffs, &obj, nil
+}
 
-	err = differ.Diff(obj, diff.Printer{}, o.ShowManagedFields)
+// runExternalDiff writes both versions of the object to temporary directories and runs the diff command
+// selected by KUBECTL_EXTERNAL_DIFF on them.
+func runExternalDiff(name string, merged, live *unstructured.Unstructured, diffOutput *bytes.Buffer, o *Options) error {
+	differ, err := diff.NewDiffer("MERGED", "LIVE")
 	if err != nil {
-		return diffOutput, &obj, fmt.Errorf("error occurered during diff: %w", err)
+		return fmt.Errorf("failed to create diff instance: %w", err)
+	}
+	defer differ.TearDown()
+
+	if err := differ.From.Print(name, merged, diff.Printer{}); err != nil {
+		return fmt.Errorf("error occurered during diff: %w", err)
+	}
+	if err := differ.To.Print(name, live, diff.Printer{}); err != nil {
+		return fmt.Errorf("error occurered during diff: %w", err)
+	}
+	var errOutput bytes.Buffer
+	err = differ.Run(&diff.DiffProgram{Exec: exec.New(), IOStreams: genericiooptions.IOStreams{In: o.IOStreams.In, Out: diffOutput, ErrOut: &errOutput}})
+	if errOutput.Len() > 0 {
+		o.errOutLock.Lock()
+		_, _ = errOutput.WriteTo(o.IOStreams.ErrOut)
+		o.errOutLock.Unlock()
 	}
-	err = differ.Run(&diff.DiffProgram{Exec: exec.New(), IOStreams: genericiooptions.IOStreams{In: o.IOStreams.In, Out: diffOutput, ErrOut: o.IOStreams.ErrOut}})
 
 	// If the diff tool runs without issues and detects differences at this level of the code, we would like to report that there are no issues
 	var exitErr exec.ExitError
 	if ok := errors.As(err, &exitErr); ok && exitErr.ExitStatus() <= 1 {
-		return diffOutput, &obj, nil
+		return nil
 	}
 	if err != nil {
-		return diffOutput, &obj, fmt.Errorf("diff exited with non-zero code: %w", err)
+		return fmt.Errorf("diff exited with non-zero code: %w", err)
 	}
-
-	return diffOutput, &obj, nil
+	return nil
 }
 
 // Run uses the factory to parse file arguments (in case of local mode) or gather all cluster resources matching
@@ -664,7 +899,7 @@ func (o *Options) Run() error {
 	if err := r.Err(); err != nil {
 		return fmt.Errorf("failed to collect resources: %w", err)
 	}
-	r.IgnoreErrors(func(err error) bool {
+	ignoredErr := func(err error) bool {
 		if strings.Contains(err.Error(), "Object 'Kind' is missing") {
 			klog.Warningf(skipInvalidResources, extractPath(err.Error(), 3), "'Kind' is missing")
 			return true
@@ -673,34 +908,38 @@ func (o *Options) Run() error {
 			klog.Warningf(skipInvalidResources, extractPath(err.Error(), 2), err.Error()[strings.LastIndex(err.Error(), ":"):])
 			return true
 		}
-		return containOnly(err, []error{UnknownMatch{}, MergeError{}, InlineDiffError{}})
-	})
+		return containOnly(err, []error{UnknownMatch{}, MergeError{}, InlineDiffError{}, MissingKeyError{}})
+	}
+	r.IgnoreErrors(ignoredErr)
 
-	err := r.Visit(func(info *resource.Info, _ error) error { // ignoring previous errors
+	// All the resources are collected before any is compared so templates can look up any of them
+	infos, err := r.Infos()
+	if err != nil {
+		return fmt.Errorf("error occurred while trying to process resources: %w", err)
+	}
+	bindLookup(o.templates, newResourceIndex(infos))
+	clusterCRs := make([]*unstructured.Unstructured, 0, len(infos))
+	infoOrder := make(map[*unstructured.Unstructured]int, len(infos))
+	for i, info := range infos {
 		clusterCRMapping, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(info.Object)
 		clusterCR := &unstructured.Unstructured{Object: clusterCRMapping}
+		clusterCRs = append(clusterCRs, clusterCR)
+		infoOrder[clusterCR] = i
+	}
 
-		temps, err := o.correlator.Match(clusterCR)
-		if err != nil && (!containOnly(err, []error{UnknownMatch{}}) || o.diffAll) {
-			o.metricsTracker.addUNMatch(clusterCR)
-		}
-		if err != nil {
-			return err
-		}
-
-		userOverrides, err := o.userOverridesCorrelator.Match(clusterCR)
-		if err != nil && !containOnly(err, []error{UnknownMatch{}}) {
-			return err //nolint: wrapcheck
-		}
-
-		temp, diffOutput, uo, err := getBestMatchByLines(temps, clusterCR, userOverrides, o)
-
-		if err != nil {
-			o.metricsTracker.addUNMatch(clusterCR)
-			return err
-		}
-
-		o.metricsTracker.addMatch(temp)
+	// The diffs are only summed up once every CR is matched, as the CRs that don't match the values of the global
+	// capture groups are diffed again
+	matched := make([]pendingMatch, 0)
+	var matchedLock sync.Mutex
+	recordMatch := func(clusterCR *unstructured.Unstructured, bestMatch matchCounts, userOverrides []*UserOverride) {
+		o.metricsTracker.addMatch(bestMatch.temp)
+		o.matchedOwners.add(clusterCR, bestMatch.temp.GetIdentifier())
+		matchedLock.Lock()
+		defer matchedLock.Unlock()
+		matched = append(matched, pendingMatch{clusterCR: clusterCR, match: bestMatch, userOverrides: userOverrides})
+	}
+	sumMatch := func(clusterCR *unstructured.Unstructured, bestMatch matchCounts, userOverrides []*UserOverride) {
+		temp, diffOutput, uo := bestMatch.temp, bestMatch.diffOutput, bestMatch.userOverride
 
 		if diffOutput.Len() > 0 {
 			numDiffCRs += 1
@@ -723,23 +962,182 @@ func (o *Options) Run() error {
 			numPatched += 1
 		}
 
+		var explanation *CorrelationExplanation
+		if o.explain {
+			e := o.correlator.Explain(clusterCR)
+			e.Candidates = bestMatch.candidates
+			explanation = &e
+		}
+
 		diffs = append(diffs, DiffSum{
 			DiffOutput:         diffOutput.String(),
+			FieldDiffs:         bestMatch.fieldDiffs,
+			CapturedValues:     bestMatch.captures,
 			CorrelatedTemplate: temp.GetIdentifier(),
 			CRName:             apiKindNamespaceName(clusterCR),
 			Patched:            patched,
 			OverrideReasons:    reasons,
 			Description:        temp.GetDescription(),
+			Explanation:        explanation,
+			Owner:              bestMatch.owner,
 		})
-		return err
+	}
+
+	// A single-instance template assigned to a CR isn't a candidate of the CRs matched in later rounds
+	assignedSingleInstance := make(map[string]bool)
+	assignPending := func(pending []pendingMatch) {
+		for _, p := range assignSingleInstanceTemplates(pending) {
+			if p.unmatched {
+				o.metricsTracker.addUNMatch(p.clusterCR)
+				o.explainUnmatched(p.clusterCR, p.match.candidates)
+				continue
+			}
+			if p.match.temp.GetConfig().GetSingleInstance() {
+				assignedSingleInstance[p.match.temp.GetIdentifier()] = true
+			}
+			recordMatch(p.clusterCR, p.match, p.userOverrides)
+		}
+	}
+
+	matchOwned := func(owned ownedMatch, temps []ReferenceTemplate, ownerOf map[string]string, pending *[]pendingMatch) error {
+		temps = slices.DeleteFunc(temps, func(temp ReferenceTemplate) bool {
+			return assignedSingleInstance[temp.GetIdentifier()]
+		})
+		if len(temps) == 0 {
+			if o.diffAll {
+				o.metricsTracker.addUNMatch(owned.clusterCR)
+			}
+			o.explainUnmatched(owned.clusterCR, nil)
+			return nil
+		}
+		bestMatch, err := getBestMatchByLines(temps, owned.clusterCR, owned.userOverrides, o)
+		if err != nil {
+			o.metricsTracker.addUNMatch(owned.clusterCR)
+			o.explainUnmatched(owned.clusterCR, bestMatch.candidates)
+			warnMissingKeys(owned.clusterCR, err)
+			if containOnly(err, []error{UnknownMatch{}, MergeError{}, InlineDiffError{}, MissingKeyError{}}) {
+				return nil
+			}
+			return err
+		}
+		bestMatch.owner = ownerOf[bestMatch.temp.GetIdentifier()]
+		if hasSingleInstanceCandidate(bestMatch) {
+			for k := range bestMatch.matches {
+				bestMatch.matches[k].owner = ownerOf[bestMatch.matches[k].temp.GetIdentifier()]
+			}
+			*pending = append(*pending, pendingMatch{clusterCR: owned.clusterCR, match: bestMatch, userOverrides: owned.userOverrides})
+			return nil
+		}
+		recordMatch(owned.clusterCR, bestMatch, owned.userOverrides)
+		return nil
+	}
+
+	err = visitConcurrently(clusterCRs, o.Concurrency, func(clusterCR *unstructured.Unstructured) error {
+		temps, err := o.correlator.Match(clusterCR)
+		if err != nil && (!containOnly(err, []error{UnknownMatch{}}) || o.diffAll) {
+			o.metricsTracker.addUNMatch(clusterCR)
+		}
+		if err != nil {
+			o.explainUnmatched(clusterCR, nil)
+			return err
+		}
+
+		userOverrides, err := o.userOverridesCorrelator.Match(clusterCR)
+		if err != nil && !containOnly(err, []error{UnknownMatch{}}) {
+			return err //nolint: wrapcheck
+		}
+
+		// A template set for the CR in the manual correlation pairs is used whatever the owners of the CR are
+		if hasOwnerTemplateCandidate(temps) && !o.manuallyCorrelated(clusterCR) {
+			o.ownedMatchesLock.Lock()
+			o.ownedMatches = append(o.ownedMatches, ownedMatch{clusterCR: clusterCR, temps: temps, userOverrides: userOverrides})
+			o.ownedMatchesLock.Unlock()
+			return nil
+		}
+
+		bestMatch, err := getBestMatchByLines(temps, clusterCR, userOverrides, o)
+
+		if err != nil {
+			o.metricsTracker.addUNMatch(clusterCR)
+			o.explainUnmatched(clusterCR, bestMatch.candidates)
+			warnMissingKeys(clusterCR, err)
+			return err
+		}
+		if hasSingleInstanceCandidate(bestMatch) {
+			o.pendingMatchesLock.Lock()
+			o.pendingMatches = append(o.pendingMatches, pendingMatch{clusterCR: clusterCR, match: bestMatch, userOverrides: userOverrides})
+			o.pendingMatchesLock.Unlock()
+			return nil
+		}
+		recordMatch(clusterCR, bestMatch, userOverrides)
+		return nil
 	})
-	if err != nil {
+	// The CRs matched or left unmatched so far are put back in the order of the resources, so the output doesn't
+	// depend on which of the concurrent visits finished first
+	unmatchedCRs := o.metricsTracker.UnMatchedCRs
+	sort.SliceStable(unmatchedCRs, func(i, j int) bool {
+		return infoOrder[unmatchedCRs[i]] < infoOrder[unmatchedCRs[j]]
+	})
+	sort.SliceStable(matched, func(i, j int) bool {
+		return infoOrder[matched[i].clusterCR] < infoOrder[matched[j].clusterCR]
+	})
+	if err := utilerrors.FilterOut(err, ignoredErr); err != nil {
 		return fmt.Errorf("error occurred while trying to process resources: %w", err)
 	}
+	assignPending(o.pendingMatches)
+
+	// CRs are matched to templates that expect an owner once one of their owners is matched to the owner template,
+	// this takes several rounds when owned CRs own other CRs. The CRs whose owners never match are matched to
+	// their other candidates. The single-instance templates are assigned to the CRs of a round once the round is over.
+	owned := o.ownedMatches
+	sort.Slice(owned, func(i, j int) bool {
+		return apiKindNamespaceName(owned[i].clusterCR) < apiKindNamespaceName(owned[j].clusterCR)
+	})
+	for len(owned) > 0 {
+		remaining := make([]ownedMatch, 0, len(owned))
+		pending := make([]pendingMatch, 0)
+		for _, m := range owned {
+			temps, ownerOf, hasOwner := o.matchedOwners.filterByOwner(m.clusterCR, m.temps)
+			if !hasOwner {
+				remaining = append(remaining, m)
+				continue
+			}
+			if err := matchOwned(m, temps, ownerOf, &pending); err != nil {
+				return fmt.Errorf("error occurred while trying to process resources: %w", err)
+			}
+		}
+		if len(remaining) == len(owned) {
+			for _, m := range remaining {
+				if err := matchOwned(m, templatesWithoutOwner(m.temps), nil, &pending); err != nil {
+					return fmt.Errorf("error occurred while trying to process resources: %w", err)
+				}
+			}
+			remaining = nil
+		}
+		assignPending(pending)
+		owned = remaining
+	}
+	if err := o.recheckGlobalCaptures(matched); err != nil {
+		if err := utilerrors.FilterOut(err, ignoredErr); err != nil {
+			return fmt.Errorf("error occurred while trying to process resources: %w", err)
+		}
+	}
+	for _, m := range matched {
+		sumMatch(m.clusterCR, m.match, m.userOverrides)
+	}
+	for _, c := range o.patternCorrelators {
+		for _, pattern := range c.UnmatchedPatterns() {
+			klog.Warningf(patternMatchedNothing, pattern)
+		}
+	}
 
-	sum := newSummary(o.ref, o.metricsTracker, numDiffCRs, o.templates, numPatched)
+	sum := newSummary(o.ref, o.metricsTracker, numDiffCRs, o.templates, o.values, numPatched, o.numSuggestions)
 
-	_, err = Output{Summary: sum, Diffs: &diffs, patches: o.newUserOverrides}.Print(o.OutputFormat, o.Out, o.verboseOutput)
+	sort.Slice(o.unmatchedExplanations, func(i, j int) bool {
+		return o.unmatchedExplanations[i].CRName < o.unmatchedExplanations[j].CRName
+	})
+	output := Output{Summary: sum, Diffs: &diffs, UnmatchedExplanations: o.unmatchedExplanations, patches: o.newUserOverrides}
+	_, err = output.Print(o.OutputFormat, o.Out, o.verboseOutput || o.explain)
 	if err != nil {
 		return err
 	}
@@ -753,6 +1151,33 @@ func (o *Options) Run() error {
 	return nil
 }
 
+// visitConcurrently calls visit for every CR with at most concurrency calls running at once. Like
+// resource.ContinueOnErrorVisitor every CR is visited whatever the others return and the errors are aggregated.
+func visitConcurrently(clusterCRs []*unstructured.Unstructured, concurrency int, visit func(*unstructured.Unstructured) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	errs := make([]error, len(clusterCRs))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, clusterCR := range clusterCRs {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			errs[i] = visit(clusterCR)
+		}()
+	}
+	wg.Wait()
+
+	errs = slices.DeleteFunc(errs, func(err error) bool { return err == nil })
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
 // InfoObject matches the diff.Object interface, it contains the objects that shall be compared.
 type InfoObject struct {
 	injectedObjFromTemplate *unstructured.Unstructured
@@ -761,12 +1186,18 @@ type InfoObject struct {
 	allowMerge              bool
 	userOverrides           []*UserOverride
 	templateFieldConf       map[string]inlineDiffType
+	listKeys                listKeys
+	unorderedLists          unorderedLists
+	embeddedDocuments       embeddedDocuments
+	capturedValues          captures
+	globalCaptures          captures
 }
 
-// Live Returns the cluster version of the object
+// Live Returns the cluster version of the object, a copy as the cluster CR is shared by every template it's diffed against
 func (obj InfoObject) Live() runtime.Object {
-	omitFields(obj.clusterObj.Object, obj.FieldsToOmit)
-	return obj.clusterObj
+	live := obj.clusterObj.DeepCopy()
+	omitFields(live.Object, obj.FieldsToOmit)
+	return live
 }
 
 type MergeError struct {
@@ -781,8 +1212,11 @@ func (e MergeError) Error() string {
 // Merged Returns the Injected Reference Version of the Resource
 func (obj InfoObject) Merged() (runtime.Object, error) {
 	var err error
+	// The inline diff funcs update the template, work on a copy so every call starts from the rendered template
+	obj.injectedObjFromTemplate = obj.injectedObjFromTemplate.DeepCopy()
+	decodeEmbeddedDocuments(obj.injectedObjFromTemplate.Object, obj.embeddedDocuments)
 	if obj.allowMerge {
-		obj.injectedObjFromTemplate, err = MergeManifests(obj.injectedObjFromTemplate, obj.clusterObj)
+		obj.injectedObjFromTemplate, err = mergeManifests(obj.injectedObjFromTemplate, obj.clusterObj, obj.listKeys)
 		if err != nil {
 			return obj.injectedObjFromTemplate, &MergeError{obj: &obj, err: err}
 		}
@@ -795,6 +1229,8 @@ func (obj InfoObject) Merged() (runtime.Object, error) {
 		}
 		obj.injectedObjFromTemplate = patched
 	}
+	// User overrides may replace an embedded document with its text
+	decodeEmbeddedDocuments(obj.injectedObjFromTemplate.Object, obj.embeddedDocuments)
 	err = obj.runInlineDiffFuncs()
 	if err != nil {
 		return obj.injectedObjFromTemplate, &InlineDiffError{obj: &obj, err: err}
@@ -803,6 +1239,41 @@ func (obj InfoObject) Merged() (runtime.Object, error) {
 	return obj.injectedObjFromTemplate, err
 }
 
+// diffableObjects returns the merged and live versions of the object in the form they are compared in.
+// Managed fields are removed unless requested and the data of secrets is masked the same way `kubectl diff` does.
+func (obj InfoObject) diffableObjects(showManagedFields bool) (merged, live *unstructured.Unstructured, err error) {
+	mergedObj, err := obj.Merged()
+	if err != nil {
+		return nil, nil, err
+	}
+	merged, ok := mergedObj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, nil, fmt.Errorf("couldn't type cast type %T to *unstructured.Unstructured", mergedObj)
+	}
+	live = obj.Live().(*unstructured.Unstructured)
+	if len(obj.unorderedLists) > 0 {
+		merged.Object = sortLists(nil, merged.Object, obj.unorderedLists).(map[string]any)
+		live.Object = sortLists(nil, live.Object, obj.unorderedLists).(map[string]any)
+	}
+	if len(obj.listKeys) > 0 {
+		merged.Object = alignLists(nil, merged.Object, live.Object, obj.listKeys).(map[string]any)
+	}
+
+	if !showManagedFields {
+		merged.SetManagedFields(nil)
+		live.SetManagedFields(nil)
+	}
+
+	if gvk := live.GroupVersionKind(); gvk.Version == "v1" && gvk.Kind == "Secret" {
+		m, err := diff.NewMasker(merged, live)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to mask secret: %w", err)
+		}
+		merged, live = m.From().(*unstructured.Unstructured), m.To().(*unstructured.Unstructured)
+	}
+	return merged, live, nil
+}
+
 type InlineDiffError struct {
 	obj *InfoObject
 	err error
@@ -814,75 +1285,114 @@ func (e InlineDiffError) Error() string {
 
 func (obj InfoObject) runInlineDiffFuncs() error {
 	var errs []error
-	for pathToKey, inlineDiffFunc := range obj.templateFieldConf {
-		listedPath, err := pathToList(pathToKey)
+	type inlineDiffField struct {
+		path       keyPath
+		inlineDiff InlineDiff
+	}
+	pathsToKey := make([]string, 0, len(obj.templateFieldConf))
+	for pathToKey := range obj.templateFieldConf {
+		pathsToKey = append(pathsToKey, pathToKey)
+	}
+	sort.Strings(pathsToKey)
+
+	fields := make([]inlineDiffField, 0)
+	for _, pathToKey := range pathsToKey {
+		pattern, err := parsePathToKey(pathToKey)
 		if err != nil {
 			errs = append(errs, fmt.Errorf("failed to parse path of field %s that uses inline diff func: %w", pathToKey, err))
 			continue
 		}
-		value, exist, err := unstructured.NestedString(obj.injectedObjFromTemplate.Object, listedPath...)
-		if err != nil || !exist {
-			errs = append(errs, fmt.Errorf("failed to acces value in template of field %s that uses inline diff func: %w", pathToKey, err))
+		fieldPaths := pattern.expand(obj.injectedObjFromTemplate.Object)
+		if len(fieldPaths) == 0 && !pattern.hasWildcards() {
+			errs = append(errs, fmt.Errorf("failed to acces value in template of field %s that uses inline diff func", pathToKey))
 			continue
 		}
-		clusterValue, exist, err := unstructured.NestedString(obj.clusterObj.Object, listedPath...)
-		if !exist {
-			continue // if value does not appear in cluster CR then there will be a diff anyway and this is not an error
+		for _, fieldPath := range fieldPaths {
+			if _, exist := fieldPath.get(obj.clusterObj.Object); !exist {
+				continue // if value does not appear in cluster CR then there will be a diff anyway and this is not an error
+			}
+			fields = append(fields, inlineDiffField{path: fieldPath, inlineDiff: InlineDiffs[obj.templateFieldConf[pathToKey]]})
 		}
-		if err != nil {
-			errs = append(errs, fmt.Errorf("failed to acces value in cluster cr of field %s that uses inline diff func: %w", pathToKey, err))
-			continue
+	}
+
+	// Capture groups are scoped to the whole CR, so they are collected from every field before any field is diffed
+	caps := make(captures)
+	for _, field := range fields {
+		if capturing, ok := field.inlineDiff.(capturingInlineDiff); ok {
+			value, _ := field.path.get(obj.injectedObjFromTemplate.Object)
+			clusterValue, _ := field.path.get(obj.clusterObj.Object)
+			caps.merge(capturing.Capture(runtime.DeepCopyJSONValue(value), runtime.DeepCopyJSONValue(clusterValue)))
 		}
-		err = unstructured.SetNestedField(obj.injectedObjFromTemplate.Object, InlineDiffs[inlineDiffFunc].Diff(value, clusterValue), listedPath...)
-		if err != nil {
-			errs = append(errs, fmt.Errorf("failed to update value of inline diff func result for field %s, %w", pathToKey, err))
-			continue
+	}
+	if obj.capturedValues != nil {
+		clear(obj.capturedValues)
+		maps.Copy(obj.capturedValues, caps)
+	}
+	// Global groups must also match the value resolved across all the matched CRs
+	for name, values := range caps {
+		if known, ok := obj.globalCaptures[name]; ok {
+			withKnown := captures{name: slices.Clone(known)}
+			withKnown.add(name, values...)
+			caps[name] = withKnown[name]
 		}
 	}
-	return errors.Join(errs...)
-}
 
-func findFieldPaths(object map[string]any, fields []*ManifestPathV1) [][]string {
-	result := make([][]string, 0)
-	for _, f := range fields {
-		if !f.IsPrefix {
-			result = append(result, f.parts)
+	for _, field := range fields {
+		value, _ := field.path.get(obj.injectedObjFromTemplate.Object)
+		clusterValue, _ := field.path.get(obj.clusterObj.Object)
+		value, clusterValue = runtime.DeepCopyJSONValue(value), runtime.DeepCopyJSONValue(clusterValue)
+		var result any
+		if capturing, ok := field.inlineDiff.(capturingInlineDiff); ok {
+			result = capturing.DiffWithCaptures(value, clusterValue, caps)
 		} else {
-			start := f.parts[:len(f.parts)-1]
-			prefix := f.parts[len(f.parts)-1]
-
-			val, _, _ := unstructured.NestedFieldNoCopy(object, start...)
-			if mapping, ok := val.(map[string]any); ok {
-				for key := range mapping {
-					if strings.HasPrefix(key, prefix) {
-						newPath := append([]string{}, start...)
-						newPath = append(newPath, key)
-						result = append(result, newPath)
-					}
-				}
-			}
+			result = field.inlineDiff.Diff(value, clusterValue)
+		}
+		if err := field.path.set(obj.injectedObjFromTemplate.Object, result); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update value of inline diff func result for field %s, %w", field.path, err))
 		}
 	}
+	return errors.Join(errs...)
+}
 
+func findFieldPaths(object map[string]any, fields []*ManifestPathV1) []keyPath {
+	result := make([]keyPath, 0)
+	for _, f := range fields {
+		result = append(result, f.path.expand(object)...)
+	}
 	return result
 }
 
 func omitFields(object map[string]any, fields []*ManifestPathV1) {
 	fieldPaths := findFieldPaths(object, fields)
 
+	removedListItems := false
 	for _, field := range fieldPaths {
-		unstructured.RemoveNestedField(object, field...)
-		for i := 0; i <= len(field); i++ {
-			val, _, _ := unstructured.NestedFieldNoCopy(object, field[:len(field)-i]...)
+		field.remove(object)
+		if field[len(field)-1].isListItem() {
+			removedListItems = true
+			continue
+		}
+		// Remove the maps that are left empty, up to the closest list
+		for i := len(field) - 1; i > 0 && field[i-1].kind == keySegment; i-- {
+			val, _ := field[:i].get(object)
 			if mapping, ok := val.(map[string]any); ok && len(mapping) == 0 {
-				unstructured.RemoveNestedField(object, field[:len(field)-i]...)
+				field[:i].remove(object)
 			}
 		}
 	}
+	if removedListItems {
+		dropRemovedListItems(object)
+	}
 }
 
 // MergeManifests will return an attempt to update the localRef with the clusterCR. In the case of an error it will return an unmodified localRef.
 func MergeManifests(localRef, clusterCR *unstructured.Unstructured) (updateLocalRef *unstructured.Unstructured, err error) {
+	return mergeManifests(localRef, clusterCR, nil)
+}
+
+// mergeManifests merges the localRef into the clusterCR as a JSON merge patch, apart from the lists in keys which
+// are merged item by item like a strategic merge patch would.
+func mergeManifests(localRef, clusterCR *unstructured.Unstructured, keys listKeys) (updateLocalRef *unstructured.Unstructured, err error) {
 	localRefData, err := json.Marshal(localRef)
 	if err != nil {
 		return localRef, fmt.Errorf("failed to marshal reference CR: %w", err)
@@ -893,17 +1403,19 @@ func MergeManifests(localRef, clusterCR *unstructured.Unstructured) (updateLocal
 		return localRef, fmt.Errorf("failed to marshal cluster CR: %w", err)
 	}
 
-	localRefUpdatedData, err := jsonpatch.MergePatch(clusterCRData, localRefData)
+	localRefObj := make(map[string]any)
+	err = json.Unmarshal(localRefData, &localRefObj)
 	if err != nil {
-		return localRef, fmt.Errorf("failed to merge cluster and reference CRs: %w", err)
+		return localRef, fmt.Errorf("failed to unmarshal reference CR: %w", err)
 	}
 
 	localRefUpdatedObj := make(map[string]any)
-	err = json.Unmarshal(localRefUpdatedData, &localRefUpdatedObj)
+	err = json.Unmarshal(clusterCRData, &localRefUpdatedObj)
 	if err != nil {
-		return localRef, fmt.Errorf("failed to unmarshal updated manifest: %w", err)
+		return localRef, fmt.Errorf("failed to unmarshal cluster CR: %w", err)
 	}
 
+	mergeValues(nil, localRefUpdatedObj, localRefObj, keys)
 	return &unstructured.Unstructured{Object: localRefUpdatedObj}, nil
 }
 
@@ -15,7 +15,9 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/openshift/kube-compare/pkg/testutils"
 	"github.com/samber/lo"
@@ -177,11 +179,14 @@ type Test struct {
 	leaveTemplateDirEmpty bool
 	mode                  []Mode
 	userConfigFileName    string
+	valuesFileName        string
 	shouldDiffAll         bool
 	outputFormat          string
 	checks                Checks
 	verboseOutput         bool
+	explain               bool
 	badAPIResources       bool
+	externalDiff          bool
 
 	userOverridePath   string
 	templToGenPatchFor []string
@@ -201,15 +206,18 @@ func (test Test) Clone() Test {
 		leaveTemplateDirEmpty: test.leaveTemplateDirEmpty,
 		mode:                  test.mode,
 		userConfigFileName:    test.userConfigFileName,
+		valuesFileName:        test.valuesFileName,
 		shouldDiffAll:         test.shouldDiffAll,
 		outputFormat:          test.outputFormat,
 		checks:                test.checks,
 		verboseOutput:         test.verboseOutput,
+		explain:               test.explain,
 		userOverridePath:      test.userOverridePath,
 		templToGenPatchFor:    slices.Clone(test.templToGenPatchFor),
 		overrideGenReason:     test.overrideGenReason,
 		referenceFileName:     test.referenceFileName,
 		badAPIResources:       test.badAPIResources,
+		externalDiff:          test.externalDiff,
 	}
 }
 
@@ -243,6 +251,12 @@ func (test Test) withUserConfig(userConfigFileName string) Test {
 	return newTest
 }
 
+func (test Test) withValues(valuesFileName string) Test {
+	newTest := test.Clone()
+	newTest.valuesFileName = valuesFileName
+	return newTest
+}
+
 func (test Test) diffAll() Test {
 	newTest := test.Clone()
 	newTest.shouldDiffAll = true
@@ -255,6 +269,12 @@ func (test Test) withVerboseOutput() Test {
 	return newTest
 }
 
+func (test Test) withExplain() Test {
+	newTest := test.Clone()
+	newTest.explain = true
+	return newTest
+}
+
 func (test Test) withOutputFormat(outputFormat string) Test {
 	newTest := test.Clone()
 	newTest.outputFormat = outputFormat
@@ -291,6 +311,12 @@ func (test Test) withBadAPIResources() Test {
 	return newTest
 }
 
+func (test Test) withExternalDiff() Test {
+	newTest := test.Clone()
+	newTest.externalDiff = true
+	return newTest
+}
+
 func (test Test) withSubTestWithMetadata(subName string) Test {
 	squashed := strings.ReplaceAll(subName, " ", "_")
 	return test.withSubTestSuffix(subName).
@@ -352,6 +378,10 @@ func TestCompareRun(t *testing.T) {
 			withUserConfig(userConfigFileName),
 		defaultTest("User Config Manual Correlation Contains Template That Doesnt Exist").
 			withUserConfig(userConfigFileName),
+		defaultTest("User Config Selector Correlation Contains Invalid Selector").
+			withUserConfig(userConfigFileName),
+		defaultTest("User Config Manual Correlation Contains Invalid Pattern").
+			withUserConfig(userConfigFileName),
 		defaultTest("Test Local Resource File Doesnt exist").
 			withModes([]Mode{{Local, LocalRef}}),
 		defaultTest("Templates Contain Kind That Is Not Recognizable In Live Cluster").
@@ -368,9 +398,30 @@ func TestCompareRun(t *testing.T) {
 		defaultTest("Custom Fields To Omit Ref Entry Not Found"),
 		defaultTest("When Using Diff All Flag - All Unmatched Resources Appear In Summary").
 			diffAll(),
+		defaultTest("Unmatched CRs Have Template Suggestions").
+			diffAll(),
+		defaultTest("Unmatched CRs Have Template Suggestions").
+			withSubTestSuffix("JSON").
+			diffAll().
+			withOutputFormat(Json).
+			withChecks(defaultChecks.withPrefixedSuffix("json")),
 		defaultTest("Manual Correlation Matches Are Prioritized Over Group Correlation").
 			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
 			withUserConfig(userConfigFileName),
+		defaultTest("Selector Correlation Matches Are Prioritized Over Group Correlation").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			withUserConfig(userConfigFileName),
+		defaultTest("Manual Correlation Patterns").
+			withUserConfig(userConfigFileName),
+		defaultTest("Correlation Explain").
+			withUserConfig(userConfigFileName).
+			withOutputFormat(Explain),
+		defaultTest("Correlation Explain").
+			withUserConfig(userConfigFileName).
+			withSubTestSuffix("JSON").
+			withExplain().
+			withOutputFormat(Json).
+			withChecks(defaultChecks.withPrefixedSuffix("json")),
 		defaultTest("Only Required Resources Of Required Component Are Reported Missing (Optional Resources Not Reported)").
 			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}),
 		defaultTest("Required Resources Of Optional Component Are Not Reported Missing").
@@ -401,6 +452,9 @@ func TestCompareRun(t *testing.T) {
 		defaultTest("SomeDiffs").
 			withVerboseOutput().
 			withChecks(defaultChecks.withPrefixedSuffix("withVebosityFlag")),
+		defaultTest("SomeDiffs").
+			withSubTestSuffix("External Diff").
+			withExternalDiff(),
 		defaultTest("Invalid Resources Are Skipped"),
 		defaultTest("Ref Contains Templates With Function Templates In Same File"),
 		defaultTest("User Override").
@@ -430,6 +484,10 @@ func TestCompareRun(t *testing.T) {
 			withSubTestSuffix("Input Exact Match").
 			withChecks(defaultChecks.withPrefixedSuffix("exactMatch")).
 			withUserOverridePath("exactMatch.patch"),
+		defaultTest("User Override").
+			withSubTestSuffix("Input Pattern Match").
+			withChecks(defaultChecks.withPrefixedSuffix("patternMatch")).
+			withUserOverridePath("patternMatch.patch"),
 		defaultTest("User Override").
 			withSubTestSuffix("Fail Load No Reason").
 			withChecks(defaultChecks.withPrefixedSuffix("noReasonLoad")).
@@ -528,6 +586,87 @@ func TestCompareRun(t *testing.T) {
 			withSubTestSuffix("With Mismatched Capturegroups").
 			withMetadataFile("metadata-with-mismatched-capturegroups.yaml").
 			withChecks(defaultChecks.withPrefixedSuffix("WithMismatchedCapturegroups")),
+		defaultTest("ReferenceV2CaptureGroupScopes"),
+		defaultTest("ReferenceV2CaptureGroupScopes").
+			withSubTestWithMetadata("global"),
+		defaultTest("ReferenceV2CaptureGroupScopes").
+			withSubTestWithMetadata("invalid"),
+		defaultTest("ReferenceV2GlobalCaptureGroupsResolution"),
+		defaultTest("ReferenceV2CaptureGroupScopes").
+			withSubTestSuffix("JSON").
+			withOutputFormat(Json).
+			withChecks(defaultChecks.withPrefixedSuffix("json")),
+		defaultTest("ReferenceV2EmbeddedDocuments"),
+		defaultTest("ReferenceV2EmbeddedDocuments").
+			withSubTestWithMetadata("invalid"),
+		defaultTest("ReferenceV2EmbeddedDocuments").
+			withSubTestSuffix("JSON").
+			withOutputFormat(Json).
+			withChecks(defaultChecks.withPrefixedSuffix("json")),
+		defaultTest("ReferenceV2CorrelationFieldGroups"),
+		defaultTest("ReferenceV2CorrelationFieldGroups").
+			withSubTestWithMetadata("invalid"),
+		defaultTest("ReferenceV2CorrelationFieldGroups").
+			withSubTestWithMetadata("non scalar"),
+		defaultTest("ReferenceV2CorrelationNonStringFields").
+			withOutputFormat(Explain),
+		defaultTest("ReferenceV2SingleInstanceAssignment"),
+		defaultTest("ReferenceV2SingleInstanceAssignment").
+			withSubTestWithMetadata("not single instance"),
+		defaultTest("ReferenceV2TemplateLookup"),
+		defaultTest("ReferenceV2Values"),
+		defaultTest("ReferenceV2Values").
+			withSubTestSuffix("site values").
+			withValues("values.yaml").
+			withChecks(defaultChecks.withPrefixedSuffix("_site_values_")),
+		defaultTest("ReferenceV2Values").
+			withSubTestSuffix("invalid values").
+			withValues("invalid-values.yaml").
+			withChecks(defaultChecks.withPrefixedSuffix("_invalid_values_")),
+		defaultTest("ReferenceV2StrictMissingKeys").diffAll(),
+		defaultTest("ReferenceV2StrictMissingKeys").
+			withSubTestWithMetadata("include").
+			diffAll(),
+		defaultTest("ReferenceV2Extends").diffAll(),
+		defaultTest("ReferenceV2Extends").
+			withSubTestWithMetadata("invalid"),
+		defaultTest("ReferenceV2IncludeAndTpl"),
+		defaultTest("ReferenceV2IncludeAndTpl").
+			withSubTestWithMetadata("recursive"),
+		defaultTest("ReferenceV2OwnerTemplates").diffAll(),
+		defaultTest("ReferenceV2OwnerTemplates").
+			withSubTestWithMetadata("invalid"),
+		defaultTest("ReferenceV2OwnerTemplates").
+			withSubTestSuffix("JSON").
+			withOutputFormat(Json).
+			withChecks(defaultChecks.withPrefixedSuffix("json")).
+			diffAll(),
+		defaultTest("ReferenceV2OwnerTemplates").
+			withSubTestWithMetadata("single instance").
+			diffAll(),
+		defaultTest("ReferenceV2OwnerTemplates").
+			withSubTestSuffix("manual pattern").
+			withUserConfig("userconfig_pattern.yaml").
+			withChecks(defaultChecks.withPrefixedSuffix("_manual_pattern_")).
+			diffAll(),
+		defaultTest("ReferenceV2InlineQuantity"),
+		defaultTest("ReferenceV2InlineQuantity").
+			withSubTestWithMetadata("with diff"),
+		defaultTest("ReferenceV2InlineQuantity").
+			withSubTestWithMetadata("invalid"),
+		defaultTest("ReferenceV2InlineNumericRange"),
+		defaultTest("ReferenceV2InlineNumericRange").
+			withSubTestWithMetadata("invalid"),
+		defaultTest("ReferenceV2InlineVersionsAndNetworks"),
+		defaultTest("ReferenceV2InlineVersionsAndNetworks").
+			withSubTestWithMetadata("with diff"),
+		defaultTest("ReferenceV2InlineVersionsAndNetworks").
+			withSubTestWithMetadata("invalid"),
+		defaultTest("ReferenceV2InlineTypedValues"),
+		defaultTest("ReferenceV2InlineTypedValues").
+			withSubTestWithMetadata("with diff"),
+		defaultTest("ReferenceV2InlineTypedValues").
+			withSubTestWithMetadata("invalid"),
 		defaultTest("ReferenceV2PerFieldMatcherValidation").
 			withSubTestSuffix("Matcher Does Not exist").
 			withMetadataFile("metadata-does-not-exist.yaml").
@@ -536,6 +675,23 @@ func TestCompareRun(t *testing.T) {
 			withSubTestSuffix("pathToKey Does Not Exist In Template").
 			withMetadataFile("metadata-path-does-not-exist-in-template.yaml").
 			withChecks(defaultChecks.withPrefixedSuffix("pathNotItTemplate")),
+		defaultTest("ReferenceV2ListMergeKey").
+			withSubTestWithMetadata("merge"),
+		defaultTest("ReferenceV2ListMergeKey").
+			withSubTestWithMetadata("no merge"),
+		defaultTest("ReferenceV2ListMergeKey").
+			withSubTestWithMetadata("invalid"),
+		defaultTest("ReferenceV2PathWildcards"),
+		defaultTest("ReferenceV2PathWildcards").
+			withSubTestSuffix("JSON").
+			withOutputFormat(Json).
+			withChecks(defaultChecks.withPrefixedSuffix("json")),
+		defaultTest("ReferenceV2UnorderedLists").
+			withSubTestWithMetadata("set"),
+		defaultTest("ReferenceV2UnorderedLists").
+			withSubTestWithMetadata("multiset"),
+		defaultTest("ReferenceV2UnorderedLists").
+			withSubTestWithMetadata("invalid"),
 		defaultTest("All Required Templates Exist And There Are No Diffs").
 			withSubTestSuffix("Bad API Resources").
 			withBadAPIResources().
@@ -629,12 +785,21 @@ func getCommand(t *testing.T, test *Test, modeIndex int, tf *cmdtesting.TestFact
 	if test.userConfigFileName != "" {
 		require.NoError(t, cmd.Flags().Set("diff-config", path.Join(test.getTestDir(), test.userConfigFileName)))
 	}
+	if test.valuesFileName != "" {
+		require.NoError(t, cmd.Flags().Set("values", path.Join(test.getTestDir(), test.valuesFileName)))
+	}
 	if test.outputFormat != "" {
 		require.NoError(t, cmd.Flags().Set("output", test.outputFormat))
 	}
 	if test.verboseOutput {
 		require.NoError(t, cmd.Flags().Set("verbose", "true"))
 	}
+	if test.explain {
+		require.NoError(t, cmd.Flags().Set("explain", "true"))
+	}
+	if test.externalDiff {
+		t.Setenv("KUBECTL_EXTERNAL_DIFF", "diff -u -N")
+	}
 	resourcesDir := path.Join(test.getTestDir(), ResourceDirName)
 	switch mode.crSource {
 	case Local:
@@ -753,3 +918,52 @@ func updateTestDiscoveryClient(tf *cmdtesting.TestFactory, discoveryResources []
 	discoveryClient.PreferredResources = append(discoveryClient.PreferredResources, &ResourceList)
 	tf.WithDiscoveryClient(discoveryClient)
 }
+
+func TestVisitConcurrently(t *testing.T) {
+	clusterCRs := make([]*unstructured.Unstructured, 20)
+	for i := range clusterCRs {
+		clusterCRs[i] = &unstructured.Unstructured{Object: map[string]any{}}
+		clusterCRs[i].SetName(fmt.Sprintf("cr-%d", i))
+	}
+	var running, maxRunning, visited atomic.Int32
+	err := visitConcurrently(clusterCRs, 3, func(cr *unstructured.Unstructured) error {
+		now := running.Add(1)
+		defer running.Add(-1)
+		for {
+			seen := maxRunning.Load()
+			if now <= seen || maxRunning.CompareAndSwap(seen, now) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		visited.Add(1)
+		if cr.GetName() == "cr-4" || cr.GetName() == "cr-11" {
+			return fmt.Errorf("failed to visit %s", cr.GetName())
+		}
+		return nil
+	})
+
+	require.Equal(t, int32(20), visited.Load(), "every CR is visited whatever the others return")
+	require.LessOrEqual(t, maxRunning.Load(), int32(3))
+	require.EqualError(t, err, "[failed to visit cr-4, failed to visit cr-11]")
+}
+
+func TestDiffableObjectsKeepTheClusterCR(t *testing.T) {
+	clusterCR := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "cm"},
+		"data":       map[string]any{"b": "2", "a": "1"},
+	}}
+	clusterCR.SetManagedFields([]v1.ManagedFieldsEntry{{Manager: "kubectl"}})
+	obj := InfoObject{
+		injectedObjFromTemplate: clusterCR.DeepCopy(),
+		clusterObj:              clusterCR,
+		capturedValues:          make(captures),
+	}
+
+	_, live, err := obj.diffableObjects(false)
+	require.NoError(t, err)
+	require.Empty(t, live.GetManagedFields())
+	require.Len(t, clusterCR.GetManagedFields(), 1, "the cluster CR is diffed against the other templates as is")
+}
@@ -5,12 +5,17 @@ package compare
 import (
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
+	"regexp"
+	"regexp/syntax"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/klog/v2"
 )
 
@@ -98,6 +103,225 @@ func (c ExactMatchCorrelator[T]) Match(object *unstructured.Unstructured) ([]T,
 	return []T{temp}, nil
 }
 
+const (
+	regexPatternPrefix = "regex:"
+	globPatternPrefix  = "glob:"
+)
+
+// isCorrelationPattern reports whether a Resource name in a manual correlation pair is a pattern
+func isCorrelationPattern(name string) bool {
+	return strings.HasPrefix(name, regexPatternPrefix) || strings.HasPrefix(name, globPatternPrefix)
+}
+
+// splitCorrelationPairs divides manual correlation pairs into pairs of exact Resource names and pairs of patterns
+func splitCorrelationPairs(pairs map[string]string) (exact, patterns map[string]string) {
+	exact = make(map[string]string)
+	patterns = make(map[string]string)
+	for name, temp := range pairs {
+		if isCorrelationPattern(name) {
+			patterns[name] = temp
+		} else {
+			exact[name] = temp
+		}
+	}
+	return exact, patterns
+}
+
+// PatternCorrelator Matches templates by patterns over the Resource names in the apiVersion_kind_namespace_name format.
+// Patterns are regular expressions prefixed with `regex:` or globs prefixed with `glob:`, where * matches any
+// characters and ? a single one. Patterns have to match the whole name.
+// When several patterns match a Resource the most specific one, the one with the most literal characters, is used.
+// Ties are decided by the alphabetical order of the patterns.
+type PatternCorrelator[T CorrelationEntry] struct {
+	patterns    []correlationPattern[T]
+	matchedLock sync.Mutex
+	matched     map[string]bool
+}
+
+type correlationPattern[T CorrelationEntry] struct {
+	pattern  string
+	re       *regexp.Regexp
+	literals int
+	entry    T
+}
+
+func NewPatternCorrelator[T CorrelationEntry](patternPairs map[string]string, templates []T) (*PatternCorrelator[T], error) {
+	core := PatternCorrelator[T]{matched: make(map[string]bool)}
+	nameToObject := make(map[string]T)
+	for _, temp := range templates {
+		nameToObject[temp.GetIdentifier()] = temp
+	}
+	for pattern, temp := range patternPairs {
+		obj, ok := nameToObject[temp]
+		if !ok {
+			return nil, fmt.Errorf("error in template manual matching for pattern: %s no template in the name of %s", pattern, temp)
+		}
+		re, err := compileCorrelationPattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error in template manual matching for pattern: %s: %w", pattern, err)
+		}
+		core.patterns = append(core.patterns, correlationPattern[T]{pattern: pattern, re: re, literals: countLiterals(re), entry: obj})
+	}
+	sort.Slice(core.patterns, func(i, j int) bool {
+		if core.patterns[i].literals != core.patterns[j].literals {
+			return core.patterns[i].literals > core.patterns[j].literals
+		}
+		return core.patterns[i].pattern < core.patterns[j].pattern
+	})
+	return &core, nil
+}
+
+func compileCorrelationPattern(pattern string) (*regexp.Regexp, error) {
+	if glob, ok := strings.CutPrefix(pattern, globPatternPrefix); ok {
+		return globToRegexp(glob)
+	}
+	expression, _ := strings.CutPrefix(pattern, regexPatternPrefix)
+	re, err := regexp.Compile("^(?:" + expression + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %w", err)
+	}
+	return re, nil
+}
+
+// countLiterals returns the number of characters a regular expression matches literally
+func countLiterals(re *regexp.Regexp) int {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return 0
+	}
+	var count func(*syntax.Regexp) int
+	count = func(r *syntax.Regexp) int {
+		n := 0
+		if r.Op == syntax.OpLiteral {
+			n = len(r.Rune)
+		}
+		for _, sub := range r.Sub {
+			n += count(sub)
+		}
+		return n
+	}
+	return count(parsed)
+}
+
+func (c *PatternCorrelator[T]) Match(object *unstructured.Unstructured) ([]T, error) {
+	p, ok := c.lookup(object)
+	if !ok {
+		return []T{}, UnknownMatch{Resource: object}
+	}
+	c.matchedLock.Lock()
+	c.matched[p.pattern] = true
+	c.matchedLock.Unlock()
+	return []T{p.entry}, nil
+}
+
+// matches returns whether any of the patterns matches the Resource, without counting the pattern as used
+func (c *PatternCorrelator[T]) matches(object *unstructured.Unstructured) bool {
+	_, ok := c.lookup(object)
+	return ok
+}
+
+// lookup returns the most specific pattern that matches the Resource
+func (c *PatternCorrelator[T]) lookup(object *unstructured.Unstructured) (correlationPattern[T], bool) {
+	name := apiKindNamespaceName(object)
+	for _, p := range c.patterns {
+		if p.re.MatchString(name) {
+			return p, true
+		}
+	}
+	return correlationPattern[T]{}, false
+}
+
+type unmatchedPatternsReporter interface {
+	UnmatchedPatterns() []string
+}
+
+// UnmatchedPatterns returns the patterns that didn't match any of the Resources matched so far
+func (c *PatternCorrelator[T]) UnmatchedPatterns() []string {
+	c.matchedLock.Lock()
+	defer c.matchedLock.Unlock()
+	var result []string
+	for _, p := range c.patterns {
+		if !c.matched[p.pattern] {
+			result = append(result, p.pattern)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// SelectorCorrelator Matches templates by label and annotation selectors. Each selector maps the Resources it selects
+// to a list of templates, of those the templates with the same apiVersion and kind as the Resource are matched.
+// Selectors are tried in order and the first one that selects the Resource and has a template of its kind is used.
+type SelectorCorrelator[T CorrelationEntry] struct {
+	selectors []templateSelector[T]
+}
+
+type templateSelector[T CorrelationEntry] struct {
+	labels      labels.Selector
+	annotations labels.Selector
+	templates   []T
+}
+
+func NewSelectorCorrelator[T CorrelationEntry](selectors []CorrelationSelector, templates []T) (*SelectorCorrelator[T], error) {
+	core := SelectorCorrelator[T]{}
+	nameToObject := make(map[string]T)
+	for _, temp := range templates {
+		nameToObject[temp.GetIdentifier()] = temp
+	}
+	for i, selector := range selectors {
+		if selector.LabelSelector == "" && selector.AnnotationSelector == "" {
+			return nil, fmt.Errorf("error in template selector correlation %d: neither labelSelector nor annotationSelector is set", i)
+		}
+		ts := templateSelector[T]{}
+		var err error
+		if selector.LabelSelector != "" {
+			if ts.labels, err = labels.Parse(selector.LabelSelector); err != nil {
+				return nil, fmt.Errorf("error in template selector correlation %d: invalid labelSelector: %w", i, err)
+			}
+		}
+		if selector.AnnotationSelector != "" {
+			if ts.annotations, err = labels.Parse(selector.AnnotationSelector); err != nil {
+				return nil, fmt.Errorf("error in template selector correlation %d: invalid annotationSelector: %w", i, err)
+			}
+		}
+		for _, temp := range selector.Templates {
+			obj, ok := nameToObject[temp]
+			if !ok {
+				return nil, fmt.Errorf("error in template selector correlation %d: no template in the name of %s", i, temp)
+			}
+			ts.templates = append(ts.templates, obj)
+		}
+		core.selectors = append(core.selectors, ts)
+	}
+	return &core, nil
+}
+
+func (c SelectorCorrelator[T]) Match(object *unstructured.Unstructured) ([]T, error) {
+	for _, selector := range c.selectors {
+		if temps := selector.match(object); len(temps) > 0 {
+			return temps, nil
+		}
+	}
+	return []T{}, UnknownMatch{Resource: object}
+}
+
+// match returns the templates of the selector with the same apiVersion and kind as the Resource, if it selects the Resource
+func (s templateSelector[T]) match(object *unstructured.Unstructured) []T {
+	if s.labels != nil && !s.labels.Matches(labels.Set(object.GetLabels())) {
+		return nil
+	}
+	if s.annotations != nil && !s.annotations.Matches(labels.Set(object.GetAnnotations())) {
+		return nil
+	}
+	var temps []T
+	for _, temp := range s.templates {
+		if temp.GetMetadata().GetAPIVersion() == object.GetAPIVersion() && temp.GetMetadata().GetKind() == object.GetKind() {
+			temps = append(temps, temp)
+		}
+	}
+	return temps
+}
+
 // GroupCorrelator Matches templates by hashing predefined fields.
 // All The templates are indexed by  hashing groups of `indexed` fields. The `indexed` fields can be nested.
 // Resources will be attempted to be matched with hashing by the group with the largest amount of `indexed` fields.
@@ -116,11 +340,29 @@ type GroupCorrelator[T CorrelationEntry] struct {
 // the fixedNamespaceKindTemplate will be added to a mapping where the keys are  in the format of `namespace_kind`. The fixedKindTemplate
 // will be added to a mapping where the keys are  in the format of `kind`.
 func NewGroupCorrelator[T CorrelationEntry](fieldGroups [][][]string, objects []T) (*GroupCorrelator[T], error) {
+	core := GroupCorrelator[T]{}
+	core.fieldCorrelators, _ = claimFieldGroups(fieldGroups, objects)
+	for _, fc := range core.fieldCorrelators {
+		err := fc.ValidateTemplates()
+		if err != nil {
+			klog.Warning(err)
+		}
+	}
+
+	return &core, nil
+}
+
+// claimFieldGroups creates a FieldCorrelator for each field group that claims any of the objects, the groups with
+// more fields claim objects first. The objects that none of the groups could claim are returned with the correlators.
+func claimFieldGroups[T CorrelationEntry](fieldGroups [][][]string, objects []T) ([]*FieldCorrelator[T], []T) {
 	sort.Slice(fieldGroups, func(i, j int) bool {
 		return len(fieldGroups[i]) >= len(fieldGroups[j])
 	})
-	core := GroupCorrelator[T]{}
+	var correlators []*FieldCorrelator[T]
 	for _, group := range fieldGroups {
+		if len(objects) == 0 {
+			break
+		}
 		fc := FieldCorrelator[T]{Fields: group, hashFunc: createGroupHashFunc(group)}
 		newObjects := fc.ClaimTemplates(objects)
 
@@ -130,19 +372,9 @@ func NewGroupCorrelator[T CorrelationEntry](fieldGroups [][][]string, objects []
 		}
 
 		objects = newObjects
-		core.fieldCorrelators = append(core.fieldCorrelators, &fc)
-
-		err := fc.ValidateTemplates()
-		if err != nil {
-			klog.Warning(err)
-		}
-
-		if len(objects) == 0 {
-			break
-		}
+		correlators = append(correlators, &fc)
 	}
-
-	return &core, nil
+	return correlators, objects
 }
 
 func getFields(fields [][]string) string {
@@ -160,12 +392,12 @@ func createGroupHashFunc(fieldGroup [][]string) templateHashFunc {
 	groupHashFunc := func(cr *unstructured.Unstructured, replaceEmptyWith string) (group string, err error) {
 		var values []string
 		for _, fields := range fieldGroup {
-			value, isFound, NotStringErr := unstructured.NestedString(cr.Object, fields...)
-			if !isFound || value == "" {
-				return "", fmt.Errorf("the field %s doesn't exist in resource", strings.Join(fields, FieldSeparator))
+			value, isFound, err := groupFieldValue(cr, fields)
+			if err != nil {
+				return "", err
 			}
-			if NotStringErr != nil {
-				return "", fmt.Errorf("the field %s isn't string - grouping by non string values isn't supported", strings.Join(fields, FieldSeparator))
+			if !isFound {
+				return "", fmt.Errorf("the field %s doesn't exist in resource", strings.Join(fields, FieldSeparator))
 			}
 			values = append(values, value)
 		}
@@ -174,6 +406,98 @@ func createGroupHashFunc(fieldGroup [][]string) templateHashFunc {
 	return groupHashFunc
 }
 
+// groupFieldValue returns the canonical form of a field of a field group, so the value of the field in a template
+// hashes the same as in a cluster CR whatever way each of them was decoded. Empty strings and lists count as missing.
+// The kind of each value prefixes it and strings are quoted, so "true" and true or "[a]" and [a] don't hash the same.
+func groupFieldValue(cr *unstructured.Unstructured, fields []string) (string, bool, error) {
+	value, isFound, err := unstructured.NestedFieldNoCopy(cr.Object, fields...)
+	if err != nil || !isFound || value == nil || value == "" {
+		return "", false, nil
+	}
+	if list, ok := value.([]any); ok {
+		if len(list) == 0 {
+			return "", false, nil
+		}
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			typed, ok := typedScalar(item)
+			if !ok {
+				return "", false, fmt.Errorf("the field %s is a list of non scalar values - grouping by these values isn't supported",
+					strings.Join(fields, FieldSeparator))
+			}
+			items = append(items, typed)
+		}
+		return "list:[" + strings.Join(items, ",") + "]", true, nil
+	}
+	typed, ok := typedScalar(value)
+	if !ok {
+		return "", false, fmt.Errorf("the field %s isn't a scalar or a list of scalars - grouping by these values isn't supported",
+			strings.Join(fields, FieldSeparator))
+	}
+	return typed, true, nil
+}
+
+// groupText formats the fields of a field group the way they're shown to users, without the kinds of the values
+func groupText(cr *unstructured.Unstructured, fieldGroup [][]string) string {
+	texts := make([]string, 0, len(fieldGroup))
+	for _, fields := range fieldGroup {
+		texts = append(texts, groupFieldText(cr, fields))
+	}
+	return strings.Join(texts, FieldSeparator)
+}
+
+func groupFieldText(cr *unstructured.Unstructured, fields []string) string {
+	value, _, _ := unstructured.NestedFieldNoCopy(cr.Object, fields...)
+	if list, ok := value.([]any); ok {
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			text, _ := canonicalScalar(item)
+			items = append(items, text)
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	}
+	text, _ := canonicalScalar(value)
+	return text
+}
+
+func typedScalar(value any) (string, bool) {
+	canonical, ok := canonicalScalar(value)
+	if !ok {
+		return "", false
+	}
+	switch value.(type) {
+	case string:
+		return "string:" + strconv.Quote(canonical), true
+	case bool:
+		return "bool:" + canonical, true
+	}
+	return "number:" + canonical, true
+}
+
+// canonicalScalar formats numbers without their type, so 3 read as an integer or as a float are the same value
+func canonicalScalar(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int:
+		return strconv.FormatInt(int64(v), 10), true
+	case int32:
+		return strconv.FormatInt(int64(v), 10), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float32:
+		return canonicalScalar(float64(v))
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return strconv.FormatInt(int64(v), 10), true
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	}
+	return "", false
+}
+
 func getTemplatesNames[T CorrelationEntry](templates []T) string {
 	var names []string
 	for _, temp := range templates {
@@ -0,0 +1,27 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGroupFieldValue(t *testing.T) {
+	valueOf := func(value any) string {
+		cr := &unstructured.Unstructured{Object: map[string]any{"spec": map[string]any{"field": value}}}
+		hash, isFound, err := groupFieldValue(cr, []string{"spec", "field"})
+		require.NoError(t, err)
+		require.True(t, isFound)
+		return hash
+	}
+
+	assert.Equal(t, valueOf(int64(3)), valueOf(float64(3)), "numbers hash the same whatever way they were decoded")
+	assert.NotEqual(t, valueOf("true"), valueOf(true))
+	assert.NotEqual(t, valueOf("3"), valueOf(int64(3)))
+	assert.NotEqual(t, valueOf("[a]"), valueOf([]any{"a"}))
+	assert.NotEqual(t, valueOf([]any{"a,b"}), valueOf([]any{"a", "b"}))
+}
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+type documentFormat string
+
+const (
+	documentFormatYAML documentFormat = "yaml"
+	documentFormatJSON documentFormat = "json"
+	documentFormatTOML documentFormat = "toml"
+	documentFormatINI  documentFormat = "ini"
+)
+
+var documentFormats = []documentFormat{documentFormatYAML, documentFormatJSON, documentFormatTOML, documentFormatINI}
+
+// embeddedDocuments maps string fields, by their path in the pathToKey syntax, to the format of the document they hold.
+// These fields are parsed before the diff so the documents are compared field by field.
+type embeddedDocuments = pathMatcher[documentFormat]
+
+// decodeEmbeddedDocuments replaces the text of the embedded documents within object with their parsed content.
+// Fields that aren't strings, because they were already decoded, are kept. Documents that fail to parse are kept
+// as text so they are still compared.
+func decodeEmbeddedDocuments(object map[string]any, documents embeddedDocuments) {
+	// Decode the outer documents first so paths of documents embedded in other documents can be expanded
+	entries := slices.Clone(documents)
+	sort.SliceStable(entries, func(i, j int) bool { return len(entries[i].path) < len(entries[j].path) })
+	for _, entry := range entries {
+		for _, fieldPath := range entry.path.expand(object) {
+			value, _ := fieldPath.get(object)
+			text, ok := value.(string)
+			if !ok || strings.TrimSpace(text) == "" {
+				continue
+			}
+			decoded, err := decodeDocument(text, entry.value)
+			if err != nil {
+				klog.Warningf("failed to parse field %s as a %s document, it will be compared as text: %s", fieldPath, entry.value, err)
+				continue
+			}
+			if err := fieldPath.set(object, decoded); err != nil {
+				klog.Warningf("failed to update field %s with its parsed %s document: %s", fieldPath, entry.value, err)
+			}
+		}
+	}
+}
+
+func decodeDocument(text string, format documentFormat) (any, error) {
+	var document any
+	var err error
+	switch format {
+	case documentFormatYAML:
+		err = yaml.Unmarshal([]byte(text), &document)
+	case documentFormatJSON:
+		err = json.Unmarshal([]byte(text), &document)
+	case documentFormatTOML:
+		tomlDocument := make(map[string]any)
+		_, err = toml.Decode(text, &tomlDocument)
+		document = tomlDocument
+	case documentFormatINI:
+		document, err = parseINI(text)
+	default:
+		err = fmt.Errorf("unknown document format %q", format)
+	}
+	if err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+	// Convert the values to the types used by unstructured objects, for example TOML dates to strings
+	data, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert document: %w", err)
+	}
+	var result any
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to convert document: %w", err)
+	}
+	return result, nil
+}
+
+// parseINI reads an INI document into a map of sections to their keys and values, keys before the first section
+// are kept at the top level. Lines starting with # or ; are comments and keys without a value are set to "".
+func parseINI(text string) (map[string]any, error) {
+	result := make(map[string]any)
+	section := result
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section name", i+1)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if name == "" {
+				return nil, fmt.Errorf("line %d: empty section name", i+1)
+			}
+			existing, ok := result[name].(map[string]any)
+			if !ok {
+				if _, isKey := result[name]; isKey {
+					return nil, fmt.Errorf("line %d: section %s has the same name as a key", i+1, name)
+				}
+				existing = make(map[string]any)
+				result[name] = existing
+			}
+			section = existing
+		default:
+			key, value, _ := strings.Cut(line, "=")
+			key = strings.TrimSpace(key)
+			if key == "" {
+				return nil, fmt.Errorf("line %d: missing key", i+1)
+			}
+			section[key] = strings.TrimSpace(value)
+		}
+	}
+	return result, nil
+}
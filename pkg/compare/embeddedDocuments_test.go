@@ -0,0 +1,46 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseINI(t *testing.T) {
+	document, err := parseINI("top=level\n; comment\n[main]\nsummary = Low latency\nflag\n\n# comment\n[sysctl]\nkernel.x=a=b\n[main]\ninclude=base")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"top":    "level",
+		"main":   map[string]any{"summary": "Low latency", "flag": "", "include": "base"},
+		"sysctl": map[string]any{"kernel.x": "a=b"},
+	}, document)
+
+	for _, invalid := range []string{"[main", "[]", "=value", "top=level\n[top]"} {
+		_, err := parseINI(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestDecodeEmbeddedDocuments(t *testing.T) {
+	object := map[string]any{
+		"data": map[string]any{
+			"outer.yaml": "inner: |\n  {\"a\": 1}\n",
+			"app.toml":   "started = 2024-01-02T03:04:05Z\n",
+			"broken":     "{",
+		},
+	}
+	decodeEmbeddedDocuments(object, newPathMatcher(map[string]documentFormat{
+		`data."outer.yaml"`:       documentFormatYAML,
+		`data."outer.yaml".inner`: documentFormatJSON,
+		`data."app.toml"`:         documentFormatTOML,
+		`data.broken`:             documentFormatJSON,
+	}))
+	assert.Equal(t, map[string]any{
+		"outer.yaml": map[string]any{"inner": map[string]any{"a": float64(1)}},
+		"app.toml":   map[string]any{"started": "2024-01-02T03:04:05Z"},
+		"broken":     "{",
+	}, object["data"])
+}
@@ -0,0 +1,342 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// maxClosestTemplates is the number of templates listed as the closest ones for a CR that wasn't correlated
+const maxClosestTemplates = 3
+
+// CorrelationExplanation describes how a cluster CR was correlated to a template: the outcome of each correlator
+// of the chain up to the one that matched, and the score of every candidate template the matching correlator returned.
+// For CRs that weren't correlated it holds the templates that came closest to hashing to the same key as the CR.
+type CorrelationExplanation struct {
+	MatchedBy        string            `json:"MatchedBy,omitempty"`
+	Steps            []CorrelatorStep  `json:"Steps"`
+	Candidates       []CandidateScore  `json:"Candidates,omitempty"`
+	ClosestTemplates []ClosestTemplate `json:"ClosestTemplates,omitempty"`
+}
+
+// CorrelatorStep is the outcome of a single correlator for a CR, Templates is empty if the correlator didn't match it
+type CorrelatorStep struct {
+	Correlator  string           `json:"Correlator"`
+	Detail      string           `json:"Detail,omitempty"`
+	FieldGroups []FieldGroupHash `json:"FieldGroups,omitempty"`
+	Templates   []string         `json:"Templates,omitempty"`
+}
+
+// FieldGroupHash is the key a CR hashed to for a group of fields and the templates indexed under that key
+type FieldGroupHash struct {
+	Fields    string   `json:"Fields"`
+	Key       string   `json:"Key,omitempty"`
+	Error     string   `json:"Error,omitempty"`
+	Templates []string `json:"Templates,omitempty"`
+}
+
+// CandidateScore is the number of differing fields between a CR and a candidate template, the candidate with the
+// lowest score is selected.
+type CandidateScore struct {
+	Template string `json:"Template"`
+	Score    int    `json:"Score"`
+	Selected bool   `json:"Selected,omitempty"`
+	Error    string `json:"Error,omitempty"`
+}
+
+// ClosestTemplate is a template indexed by a group of fields of which only some have the same value as in the CR
+type ClosestTemplate struct {
+	Template        string   `json:"Template"`
+	MatchingFields  []string `json:"MatchingFields,omitempty"`
+	DifferingFields []string `json:"DifferingFields,omitempty"`
+}
+
+// UnmatchedExplanation is the explanation of a CR that wasn't correlated to any template
+type UnmatchedExplanation struct {
+	CRName      string                 `json:"CRName"`
+	Explanation CorrelationExplanation `json:"Explanation"`
+}
+
+// correlationExplainer is implemented by correlators that can report how they handle a CR
+type correlationExplainer interface {
+	explain(object *unstructured.Unstructured) CorrelatorStep
+}
+
+// closestTemplatesFinder is implemented by correlators that can report which templates almost matched a CR
+type closestTemplatesFinder interface {
+	closestTemplates(object *unstructured.Unstructured) []ClosestTemplate
+}
+
+// Explain runs the CR through the correlators the same way Match does and reports the outcome of every correlator
+// that was tried. In case none of them matched the closest templates are reported.
+func (c MultiCorrelator[T]) Explain(object *unstructured.Unstructured) CorrelationExplanation {
+	result := CorrelationExplanation{Steps: make([]CorrelatorStep, 0)}
+	for _, core := range c.correlators {
+		var step CorrelatorStep
+		if e, ok := core.(correlationExplainer); ok {
+			step = e.explain(object)
+		} else {
+			step = CorrelatorStep{Correlator: correlatorName(core)}
+			temps, err := core.Match(object)
+			if err == nil {
+				step.Templates = templateIdentifiers(temps)
+			}
+		}
+		result.Steps = append(result.Steps, step)
+		if len(step.Templates) > 0 {
+			result.MatchedBy = step.Correlator
+			return result
+		}
+	}
+
+	closest := make(map[string]ClosestTemplate)
+	for _, core := range c.correlators {
+		finder, ok := core.(closestTemplatesFinder)
+		if !ok {
+			continue
+		}
+		for _, candidate := range finder.closestTemplates(object) {
+			if current, seen := closest[candidate.Template]; !seen || closerThan(candidate, current) {
+				closest[candidate.Template] = candidate
+			}
+		}
+	}
+	for _, candidate := range closest {
+		result.ClosestTemplates = append(result.ClosestTemplates, candidate)
+	}
+	sort.Slice(result.ClosestTemplates, func(i, j int) bool {
+		a, b := result.ClosestTemplates[i], result.ClosestTemplates[j]
+		if closerThan(a, b) != closerThan(b, a) {
+			return closerThan(a, b)
+		}
+		return a.Template < b.Template
+	})
+	if len(result.ClosestTemplates) > maxClosestTemplates {
+		result.ClosestTemplates = result.ClosestTemplates[:maxClosestTemplates]
+	}
+	return result
+}
+
+// closerThan reports whether a has more fields with the same value as the CR than b,
+// or the same number of them and fewer differing fields
+func closerThan(a, b ClosestTemplate) bool {
+	if len(a.MatchingFields) != len(b.MatchingFields) {
+		return len(a.MatchingFields) > len(b.MatchingFields)
+	}
+	return len(a.DifferingFields) < len(b.DifferingFields)
+}
+
+func correlatorName(core any) string {
+	name := fmt.Sprintf("%T", core)
+	name, _, _ = strings.Cut(name, "[")
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+func templateIdentifiers[T CorrelationEntry](templates []T) []string {
+	names := make([]string, 0, len(templates))
+	for _, temp := range templates {
+		names = append(names, temp.GetIdentifier())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c ExactMatchCorrelator[T]) explain(object *unstructured.Unstructured) CorrelatorStep {
+	step := CorrelatorStep{Correlator: "ExactMatchCorrelator"}
+	name := apiKindNamespaceName(object)
+	if temp, ok := c.apiKindNamespaceName[name]; ok {
+		step.Detail = fmt.Sprintf("correlation pair %s", name)
+		step.Templates = []string{temp.GetIdentifier()}
+	}
+	return step
+}
+
+// explain doesn't go through Match so explaining a CR doesn't count as a use of the pattern
+func (c *PatternCorrelator[T]) explain(object *unstructured.Unstructured) CorrelatorStep {
+	step := CorrelatorStep{Correlator: "PatternCorrelator"}
+	name := apiKindNamespaceName(object)
+	for _, p := range c.patterns {
+		if p.re.MatchString(name) {
+			step.Detail = fmt.Sprintf("correlation pattern %s", p.pattern)
+			step.Templates = []string{p.entry.GetIdentifier()}
+			break
+		}
+	}
+	return step
+}
+
+func (c SelectorCorrelator[T]) explain(object *unstructured.Unstructured) CorrelatorStep {
+	step := CorrelatorStep{Correlator: "SelectorCorrelator"}
+	for _, selector := range c.selectors {
+		temps := selector.match(object)
+		if len(temps) == 0 {
+			continue
+		}
+		var selectors []string
+		if selector.labels != nil {
+			selectors = append(selectors, fmt.Sprintf("labelSelector %s", selector.labels))
+		}
+		if selector.annotations != nil {
+			selectors = append(selectors, fmt.Sprintf("annotationSelector %s", selector.annotations))
+		}
+		step.Detail = strings.Join(selectors, ", ")
+		step.Templates = templateIdentifiers(temps)
+		break
+	}
+	return step
+}
+
+func (c *GroupCorrelator[T]) explain(object *unstructured.Unstructured) CorrelatorStep {
+	step := CorrelatorStep{Correlator: "GroupCorrelator"}
+	for _, fc := range c.fieldCorrelators {
+		group := FieldGroupHash{Fields: fieldPaths(fc.Fields)}
+		key, err := fc.hashFunc(object, "")
+		if err != nil {
+			group.Error = err.Error()
+			step.FieldGroups = append(step.FieldGroups, group)
+			continue
+		}
+		group.Key = groupText(object, fc.Fields)
+		group.Templates = templateIdentifiers(fc.objects[key])
+		step.FieldGroups = append(step.FieldGroups, group)
+		if len(group.Templates) > 0 {
+			step.Templates = group.Templates
+			break
+		}
+	}
+	return step
+}
+
+// closestTemplates compares the CR with every template of its kind on the fields of the group the template is indexed by
+func (c *GroupCorrelator[T]) closestTemplates(object *unstructured.Unstructured) []ClosestTemplate {
+	result := make([]ClosestTemplate, 0)
+	for _, fc := range c.fieldCorrelators {
+		for _, temps := range fc.objects {
+			for _, temp := range temps {
+				if temp.GetMetadata().GetKind() != object.GetKind() {
+					continue
+				}
+				candidate := ClosestTemplate{Template: temp.GetIdentifier()}
+				for _, field := range fc.Fields {
+					expected, _, _ := groupFieldValue(temp.GetMetadata(), field)
+					actual, _, _ := groupFieldValue(object, field)
+					if expected == actual {
+						candidate.MatchingFields = append(candidate.MatchingFields, strings.Join(field, "."))
+					} else {
+						candidate.DifferingFields = append(candidate.DifferingFields, strings.Join(field, "."))
+					}
+				}
+				if len(candidate.MatchingFields) > 0 {
+					result = append(result, candidate)
+				}
+			}
+		}
+	}
+	return result
+}
+
+func fieldPaths(fields [][]string) string {
+	paths := make([]string, 0, len(fields))
+	for _, field := range fields {
+		paths = append(paths, strings.Join(field, "."))
+	}
+	return strings.Join(paths, ", ")
+}
+
+// candidateScores lists the number of differing fields of every candidate template, in the order they were tried
+func candidateScores(matches []matchCounts, best matchCounts, errs map[string]error) []CandidateScore {
+	result := make([]CandidateScore, 0, len(matches)+len(errs))
+	for _, match := range matches {
+		result = append(result, CandidateScore{
+			Template: match.temp.GetIdentifier(),
+			Score:    match.differingFieldCount(),
+			Selected: best.temp != nil && match.temp.GetIdentifier() == best.temp.GetIdentifier(),
+		})
+	}
+	names := make([]string, 0, len(errs))
+	for name := range errs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		result = append(result, CandidateScore{Template: name, Score: -1, Error: errs[name].Error()})
+	}
+	return result
+}
+
+func (e CorrelationExplanation) String() string {
+	var b strings.Builder
+	if e.MatchedBy != "" {
+		fmt.Fprintf(&b, "Matched By: %s\n", e.MatchedBy)
+	} else {
+		b.WriteString("Matched By: None\n")
+	}
+	b.WriteString("Correlators:\n")
+	for _, step := range e.Steps {
+		result := "no match"
+		if len(step.Templates) > 0 {
+			result = strings.Join(step.Templates, ", ")
+		}
+		fmt.Fprintf(&b, "- %s: %s\n", step.Correlator, result)
+		if step.Detail != "" {
+			fmt.Fprintf(&b, "  Matched %s\n", step.Detail)
+		}
+		for _, group := range step.FieldGroups {
+			fmt.Fprintf(&b, "  - Fields: %s\n", group.Fields)
+			if group.Error != "" {
+				fmt.Fprintf(&b, "    Error: %s\n", group.Error)
+				continue
+			}
+			fmt.Fprintf(&b, "    Key: %s\n", group.Key)
+			if len(group.Templates) > 0 {
+				fmt.Fprintf(&b, "    Templates: %s\n", strings.Join(group.Templates, ", "))
+			} else {
+				b.WriteString("    Templates: None\n")
+			}
+		}
+	}
+	if len(e.Candidates) > 0 {
+		b.WriteString("Candidates:\n")
+		for _, candidate := range e.Candidates {
+			switch {
+			case candidate.Error != "":
+				fmt.Fprintf(&b, "- %s: failed: %s\n", candidate.Template, candidate.Error)
+			case candidate.Selected:
+				fmt.Fprintf(&b, "- %s: %s (selected)\n", candidate.Template, differingFields(candidate.Score))
+			default:
+				fmt.Fprintf(&b, "- %s: %s\n", candidate.Template, differingFields(candidate.Score))
+			}
+		}
+	}
+	if len(e.ClosestTemplates) > 0 {
+		b.WriteString("Closest Templates:\n")
+		for _, candidate := range e.ClosestTemplates {
+			fmt.Fprintf(&b, "- %s: same %s", candidate.Template, strings.Join(candidate.MatchingFields, ", "))
+			if len(candidate.DifferingFields) > 0 {
+				fmt.Fprintf(&b, ", different %s", strings.Join(candidate.DifferingFields, ", "))
+			}
+			b.WriteString("\n")
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func differingFields(n int) string {
+	if n == 1 {
+		return "1 differing field"
+	}
+	return fmt.Sprintf("%d differing fields", n)
+}
+
+func (e UnmatchedExplanation) String() string {
+	return fmt.Sprintf("Unmatched Cluster CR: %s\nCorrelation:\n%s", e.CRName, indent(e.Explanation.String(), 2))
+}
+
+func indent(s string, spaces int) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
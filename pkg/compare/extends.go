@@ -0,0 +1,363 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// OverlayActions are set on the parts, components and templates of a reference that extends another. Without them
+// a part, component or template is merged into the one of the base reference with the same name, or path for
+// templates, and it's added when the base reference has none.
+type OverlayActions struct {
+	// Remove removes the part, component or template from the base reference
+	Remove bool `json:"remove,omitempty"`
+	// Replace replaces the part, component or template of the base reference instead of merging into it
+	Replace bool `json:"replace,omitempty"`
+}
+
+func (a *OverlayActions) overlayActions() *OverlayActions {
+	return a
+}
+
+// unmatchedError reports actions left once the reference is merged into the references it extends, these actions
+// had nothing to remove or replace
+func (a *OverlayActions) unmatchedError(item string) error {
+	if !a.Remove && !a.Replace {
+		return nil
+	}
+	return fmt.Errorf("%s is set to be removed or replaced but it isn't in a reference extended by the reference", item)
+}
+
+// validateOverlayActions checks that no part, component or template of the part is left with an overlay action
+func (p *PartV2) validateOverlayActions() []error {
+	var errs []error
+	if err := p.unmatchedError("part " + p.Name); err != nil {
+		errs = append(errs, err)
+	}
+	for _, comp := range p.Components {
+		if err := comp.unmatchedError(fmt.Sprintf("component %s of part %s", comp.Name, p.Name)); err != nil {
+			errs = append(errs, err)
+		}
+		for _, g := range comp.groups() {
+			for _, temp := range g.templateList() {
+				if err := temp.unmatchedError(fmt.Sprintf("template %s of component %s", temp.Path, comp.Name)); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// readReferenceV2 reads a v2 reference and merges it into the reference it extends, the references extended by the
+// base reference are merged into it first. chain holds the locations of the references read so far to find
+// references that extend each other.
+func readReferenceV2(fsys fs.FS, referenceFileName string, chain []string) (*ReferenceV2, error) {
+	result := &ReferenceV2{}
+	err := parseYaml(fsys, referenceFileName, &result, refConfNotExistsError, refConfigNotInFormat)
+	if err != nil || result.Extends == "" {
+		return result, err
+	}
+	baseFS, baseFileName, err := resolveExtends(fsys, referenceFileName, result.Extends)
+	if err != nil {
+		return result, fmt.Errorf("failed to find the reference extended by %s: %w", referenceFileName, err)
+	}
+	location := referenceLocation(baseFS, baseFileName)
+	if location == baseFileName {
+		// The base reference is in a subdirectory of the reference, its location is relative to the reference
+		location = path.Join(path.Dir(chain[len(chain)-1]), filepath.ToSlash(result.Extends))
+	}
+	chain = append(chain, location)
+	if slices.Contains(chain[:len(chain)-1], location) {
+		return result, fmt.Errorf("references extend each other: %s", strings.Join(chain, " -> "))
+	}
+	base, err := readReferenceV2(baseFS, baseFileName, chain)
+	if err != nil {
+		return result, fmt.Errorf("failed to read the reference %s extended by %s: %w", result.Extends, referenceFileName, err)
+	}
+	if !strings.EqualFold(strings.TrimSpace(base.Version), ReferenceVersionV2) {
+		return result, fmt.Errorf("reference %s extended by %s isn't a v2 reference", result.Extends, referenceFileName)
+	}
+	base.extendedFS = append([]fs.FS{baseFS}, base.extendedFS...)
+	base.nestFileLayers()
+	return base, base.merge(result)
+}
+
+// resolveExtends returns the directory and file name of the reference extended by a reference. Relative paths are
+// relative to the directory of the reference, they may point outside of it when the reference is read from a local
+// directory or a URL.
+func resolveExtends(fsys fs.FS, referenceFileName, extends string) (fs.FS, string, error) {
+	if isURL(extends) || filepath.IsAbs(extends) {
+		baseFS, err := GetRefFS(extends)
+		return baseFS, path.Base(filepath.ToSlash(extends)), err
+	}
+	target := path.Join(path.Dir(referenceFileName), filepath.ToSlash(extends))
+	switch refFS := fsys.(type) {
+	case HTTPFS:
+		dir, err := url.JoinPath(refFS.baseURL, path.Dir(target))
+		if err != nil {
+			return nil, "", fmt.Errorf("could not construct url: %w", err)
+		}
+		return HTTPFS{baseURL: dir, httpGet: refFS.httpGet}, path.Base(target), nil
+	case dirFS:
+		baseFS, err := GetRefFS(filepath.Join(refFS.dir, filepath.FromSlash(target)))
+		return baseFS, path.Base(target), err
+	}
+	if !fs.ValidPath(target) {
+		return nil, "", fmt.Errorf("%s is outside of the directory of the reference", extends)
+	}
+	baseFS, err := fs.Sub(fsys, path.Dir(target))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open the directory of %s: %w", extends, err)
+	}
+	return baseFS, path.Base(target), nil
+}
+
+// referenceLocation returns the absolute path or URL of a reference file when it's known, otherwise the name of
+// the file is returned
+func referenceLocation(fsys fs.FS, referenceFileName string) string {
+	switch refFS := fsys.(type) {
+	case HTTPFS:
+		if location, err := url.JoinPath(refFS.baseURL, referenceFileName); err == nil {
+			return location
+		}
+	case dirFS:
+		return filepath.Join(refFS.dir, filepath.FromSlash(referenceFileName))
+	}
+	return referenceFileName
+}
+
+// dirFS is the directory of a local reference, the path of the directory is kept to find the references it
+// extends outside of it
+type dirFS struct {
+	fs.FS
+	dir string
+}
+
+func newDirFS(dir string) dirFS {
+	return dirFS{FS: os.DirFS(dir), dir: dir}
+}
+
+// fileLayerFS reads each file from the layer it's declared in, layers holds the directory of the reference and then
+// the directories of the references it extends, nearest first. Files missing from layerOf are read from the
+// directory of the reference.
+type fileLayerFS struct {
+	layers  []fs.FS
+	layerOf map[string]int
+}
+
+func (l fileLayerFS) Open(name string) (fs.File, error) {
+	return l.layers[l.layerOf[name]].Open(name) // nolint:wrapcheck
+}
+
+// ReferenceFS returns the file system the templateFunctionFiles of the reference are read from. The files declared
+// by a reference it extends are read from the directory of that reference, so files of the same name in the
+// directory of the reference don't shadow them.
+func ReferenceFS(ref Reference, fsys fs.FS) fs.FS {
+	refV2, ok := ref.(*ReferenceV2)
+	if !ok || len(refV2.extendedFS) == 0 {
+		return fsys
+	}
+	return fileLayerFS{layers: append([]fs.FS{fsys}, refV2.extendedFS...), layerOf: refV2.functionFileLayers}
+}
+
+// templateFS returns the file system the template is read from, the directory of the reference that declared it or
+// last replaced it
+func (r *ReferenceV2) templateFS(fsys fs.FS, temp *ReferenceTemplateV2) fs.FS {
+	if temp.layer == 0 {
+		return fsys
+	}
+	return r.extendedFS[temp.layer-1]
+}
+
+// nestFileLayers moves the files of the reference one layer down, as the reference becomes the base of an overlay
+// whose directory is the first layer
+func (r *ReferenceV2) nestFileLayers() {
+	for _, part := range r.Parts {
+		for _, comp := range part.Components {
+			for _, g := range comp.groups() {
+				for _, temp := range g.templateList() {
+					temp.layer++
+				}
+			}
+		}
+	}
+	layers := make(map[string]int, len(r.TemplateFunctionFiles))
+	for _, file := range r.TemplateFunctionFiles {
+		layers[file] = r.functionFileLayers[file] + 1
+	}
+	r.functionFileLayers = layers
+}
+
+// merge merges the overlay into the base reference r
+func (r *ReferenceV2) merge(overlay *ReferenceV2) error {
+	r.Version = overlay.Version
+	r.Extends = overlay.Extends
+	r.TemplateFunctionFiles = appendMissing(r.TemplateFunctionFiles, overlay.TemplateFunctionFiles)
+	r.GlobalCaptureGroups = appendMissing(r.GlobalCaptureGroups, overlay.GlobalCaptureGroups)
+	if overlay.StrictMissingKeys != nil {
+		r.StrictMissingKeys = overlay.StrictMissingKeys
+	}
+	if overlay.Correlation != nil {
+		r.Correlation = overlay.Correlation
+	}
+	if overlay.ValuesSchema != nil {
+		r.ValuesSchema = overlay.ValuesSchema
+	}
+	if r.Values == nil {
+		r.Values = overlay.Values
+	} else {
+		coalesceValues(r.Values, overlay.Values)
+	}
+	if r.FieldsToOmit == nil {
+		r.FieldsToOmit = overlay.FieldsToOmit
+	} else {
+		r.FieldsToOmit.merge(overlay.FieldsToOmit)
+	}
+
+	var errs []error
+	r.Parts, errs = mergeOverlay("part", r.Parts, overlay.Parts, func(p *PartV2) string { return p.Name }, (*PartV2).merge)
+	return errors.Join(errs...)
+}
+
+// merge appends the entries of the items of the overlay to the entries of the items of the base with the same key
+func (toOmit *FieldsToOmitV2) merge(overlay *FieldsToOmitV2) {
+	if overlay == nil {
+		return
+	}
+	if overlay.DefaultOmitRef != "" {
+		toOmit.DefaultOmitRef = overlay.DefaultOmitRef
+	}
+	if toOmit.Items == nil {
+		toOmit.Items = make(map[string][]*FieldsToOmitV2Entry)
+	}
+	for key, entries := range overlay.Items {
+		toOmit.Items[key] = append(toOmit.Items[key], entries...)
+	}
+}
+
+func (p *PartV2) merge(overlay *PartV2) []error {
+	if overlay.Description != "" {
+		p.Description = overlay.Description
+	}
+	if overlay.Correlation != nil {
+		p.Correlation = overlay.Correlation
+	}
+	var errs []error
+	p.Components, errs = mergeOverlay(fmt.Sprintf("component of part %s", p.Name), p.Components, overlay.Components,
+		func(c *ComponentV2) string { return c.Name }, (*ComponentV2).merge)
+	return errs
+}
+
+// merge merges the templates of the overlay into the templates of the component, the overlay has to list them
+// under the same key as the component unless it replaces the component.
+func (comp *ComponentV2) merge(overlay *ComponentV2) []error {
+	if overlay.Description != "" {
+		comp.Description = overlay.Description
+	}
+	var errs []error
+	groups := comp.groups()
+	for i, g := range overlay.groups() {
+		if len(g.templateList()) == 0 {
+			continue
+		}
+		for j, baseGroup := range groups {
+			if j != i && len(baseGroup.templateList()) > 0 {
+				errs = append(errs, fmt.Errorf("component %s lists its templates under %s in the base reference and "+
+					"under %s in the overlay, set replace to replace the component", comp.Name,
+					getFieldNameFromStructTag(comp, baseGroup), getFieldNameFromStructTag(comp, g)))
+			}
+		}
+		templates, templateErrs := mergeOverlay(fmt.Sprintf("template of component %s", comp.Name),
+			groups[i].templateList(), g.templateList(), (*ReferenceTemplateV2).GetPath, (*ReferenceTemplateV2).merge)
+		groups[i].SetTemplates(templates)
+		errs = append(errs, templateErrs...)
+	}
+	return errs
+}
+
+// merge patches the template with the fields the overlay sets, maps like the config are merged key by key and
+// other values, lists included, are replaced.
+func (rf *ReferenceTemplateV2) merge(overlay *ReferenceTemplateV2) []error {
+	patched, err := templateValues(rf)
+	if err != nil {
+		return []error{err}
+	}
+	patch, err := templateValues(overlay)
+	if err != nil {
+		return []error{err}
+	}
+	coalesceValues(patched, patch)
+	data, err := json.Marshal(patched)
+	if err != nil {
+		return []error{fmt.Errorf("failed to patch template %s: %w", rf.Path, err)}
+	}
+	// The patched template is still read from the reference that declared it
+	layer := rf.layer
+	*rf = ReferenceTemplateV2{layer: layer}
+	if err := json.Unmarshal(data, rf); err != nil {
+		return []error{fmt.Errorf("failed to patch template %s: %w", rf.Path, err)}
+	}
+	return nil
+}
+
+func templateValues(rf *ReferenceTemplateV2) (map[string]any, error) {
+	data, err := json.Marshal(rf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to patch template %s: %w", rf.Path, err)
+	}
+	values := make(map[string]any)
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to patch template %s: %w", rf.Path, err)
+	}
+	return values, nil
+}
+
+type overlayItem interface {
+	overlayActions() *OverlayActions
+}
+
+// mergeOverlay applies the items of the overlay to the items of the base with the same key: they're removed,
+// replaced or merged into them following the overlay actions. The items of the overlay that aren't in the base are
+// appended with their actions, which are reported when the reference is validated.
+func mergeOverlay[T overlayItem](kind string, base, overlay []T, key func(T) string, merge func(T, T) []error) ([]T, []error) {
+	var errs []error
+	for _, item := range overlay {
+		actions := item.overlayActions()
+		i := slices.IndexFunc(base, func(b T) bool { return key(b) == key(item) })
+		switch {
+		case i < 0:
+			base = append(base, item)
+		case actions.Remove && actions.Replace:
+			errs = append(errs, fmt.Errorf("%s %s is set to be both removed and replaced", kind, key(item)))
+		case actions.Remove:
+			base = slices.Delete(base, i, i+1)
+		case actions.Replace:
+			actions.Replace = false
+			base[i] = item
+		default:
+			errs = append(errs, merge(base[i], item)...)
+		}
+	}
+	return base, errs
+}
+
+// appendMissing appends the values of overlay that aren't in base
+func appendMissing(base, overlay []string) []string {
+	for _, value := range overlay {
+		if !slices.Contains(base, value) {
+			base = append(base, value)
+		}
+	}
+	return base
+}
@@ -0,0 +1,175 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const configMapTemplate = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .metadata.name }}
+`
+
+func TestExtendsOverHTTP(t *testing.T) {
+	files := fstest.MapFS{
+		"vendor/base/metadata.yaml": {Data: []byte(`apiVersion: v2
+parts:
+  - name: Part
+    components:
+      - name: Component
+        allOf:
+          - path: cm.yaml
+          - path: secret.yaml
+`)},
+		"vendor/base/cm.yaml":     {Data: []byte(configMapTemplate)},
+		"vendor/base/secret.yaml": {Data: []byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: base\n")},
+		"sites/site/metadata.yaml": {Data: []byte(`apiVersion: v2
+extends: ../../vendor/base/metadata.yaml
+parts:
+  - name: Part
+    components:
+      - name: Component
+        allOf:
+          - path: cm.yaml
+            description: patched
+          - path: secret.yaml
+            replace: true
+`)},
+		"sites/site/cm.yaml":     {Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: site\n")},
+		"sites/site/secret.yaml": {Data: []byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: site\n")},
+	}
+	svr := httptest.NewServer(http.FileServer(http.FS(files)))
+	defer svr.Close()
+
+	fsys, err := GetRefFS(svr.URL + "/sites/site/metadata.yaml")
+	require.NoError(t, err)
+	ref, err := GetReference(fsys, "metadata.yaml")
+	require.NoError(t, err)
+	templates, err := ParseTemplates(ref, fsys)
+	require.NoError(t, err)
+
+	require.Len(t, templates, 2)
+	assert.Equal(t, "patched", templates[0].GetDescription())
+	assert.Equal(t, "", templates[0].GetMetadata().GetName(), "templates the overlay doesn't replace are read from the base")
+	assert.Equal(t, "site", templates[1].GetMetadata().GetName(), "templates the overlay replaces are read from the overlay")
+}
+
+func TestExtendsReadsBaseFilesFromTheBase(t *testing.T) {
+	fsys := fstest.MapFS{
+		"base/metadata.yaml": {Data: []byte(`apiVersion: v2
+templateFunctionFiles:
+  - helpers.tmpl
+parts:
+  - name: Part
+    components:
+      - name: Component
+        allOf:
+          - path: cm.yaml
+`)},
+		"base/cm.yaml":       {Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ include \"name\" . }}\n")},
+		"base/helpers.tmpl":  {Data: []byte(`{{- define "name" }}base{{ end -}}`)},
+		"site/metadata.yaml": {Data: []byte("apiVersion: v2\nextends: ../base/metadata.yaml\nparts: []\n")},
+		"site/cm.yaml":       {Data: []byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: site\n")},
+		"site/helpers.tmpl":  {Data: []byte(`{{- define "name" }}site{{ end -}}`)},
+	}
+	site, err := fs.Sub(fsys, "site")
+	require.NoError(t, err)
+	ref, err := GetReference(fsys, "site/metadata.yaml")
+	require.NoError(t, err)
+	templates, err := ParseTemplates(ref, site)
+	require.NoError(t, err)
+
+	require.Len(t, templates, 1)
+	assert.Equal(t, "ConfigMap", templates[0].GetMetadata().GetKind(), "files in the overlay don't shadow the templates of the base")
+	assert.Equal(t, "base", templates[0].GetMetadata().GetName(), "nor its templateFunctionFiles")
+}
+
+func TestExtendsStrictMissingKeys(t *testing.T) {
+	for _, test := range []struct {
+		overlay  string
+		expected bool
+	}{
+		{overlay: "", expected: true},
+		{overlay: "strictMissingKeys: false\n", expected: false},
+	} {
+		fsys := fstest.MapFS{
+			"base/metadata.yaml": {Data: []byte("apiVersion: v2\nstrictMissingKeys: true\nparts: []\n")},
+			"metadata.yaml":      {Data: []byte("apiVersion: v2\nextends: base/metadata.yaml\nparts: []\n" + test.overlay)},
+		}
+		ref, err := readReferenceV2(fsys, "metadata.yaml", []string{"metadata.yaml"})
+		require.NoError(t, err)
+		require.NotNil(t, ref.StrictMissingKeys)
+		assert.Equal(t, test.expected, *ref.StrictMissingKeys, "overlay: %q", test.overlay)
+	}
+}
+
+func TestExtendsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata string
+		errorMsg string
+	}{
+		{
+			name:     "extends itself",
+			metadata: "apiVersion: v2\nextends: metadata.yaml\nparts: []\n",
+			errorMsg: "references extend each other: metadata.yaml -> metadata.yaml",
+		},
+		{
+			name:     "outside of the directory",
+			metadata: "apiVersion: v2\nextends: ../metadata.yaml\nparts: []\n",
+			errorMsg: "../metadata.yaml is outside of the directory of the reference",
+		},
+		{
+			name: "nothing to remove",
+			metadata: `apiVersion: v2
+extends: base/metadata.yaml
+parts:
+  - name: Part
+    components:
+      - name: Other
+        remove: true
+`,
+			errorMsg: "component Other of part Part is set to be removed or replaced but it isn't in a reference extended by the reference",
+		},
+		{
+			name: "without extends",
+			metadata: `apiVersion: v2
+parts:
+  - name: Part
+    components:
+      - name: Component
+        allOf:
+          - path: cm.yaml
+            replace: true
+`,
+			errorMsg: "template cm.yaml of component Component is set to be removed or replaced but it isn't in a reference extended by the reference",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fsys := fstest.MapFS{
+				"metadata.yaml": {Data: []byte(test.metadata)},
+				"base/metadata.yaml": {Data: []byte(`apiVersion: v2
+parts:
+  - name: Part
+    components:
+      - name: Component
+        allOf:
+          - path: cm.yaml
+`)},
+			}
+			_, err := GetReference(fsys, "metadata.yaml")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.errorMsg)
+		})
+	}
+}
@@ -0,0 +1,164 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+type FieldDiffType string
+
+const (
+	// FieldMissing the field appears in the template but not in the cluster CR
+	FieldMissing FieldDiffType = "missing"
+	// FieldUnexpected the field appears in the cluster CR but not in the template
+	FieldUnexpected FieldDiffType = "unexpected"
+	// FieldChanged the field appears in both but with different values
+	FieldChanged FieldDiffType = "changed"
+)
+
+// FieldDiff is a single field that differs between a rendered template and a cluster CR.
+// The path is in the pathToKey syntax used in the reference config.
+type FieldDiff struct {
+	Path       string        `json:"Path"`
+	Expected   any           `json:"Expected,omitempty"`
+	Actual     any           `json:"Actual,omitempty"`
+	ChangeType FieldDiffType `json:"ChangeType"`
+}
+
+func newFieldDiff(path keyPath, expected, actual any, changeType FieldDiffType) FieldDiff {
+	return FieldDiff{Path: path.String(), Expected: expected, Actual: actual, ChangeType: changeType}
+}
+
+// diffFields walks the rendered template (expected) and the cluster CR (actual) together and returns
+// every field that differs. Subtrees that only exist on one side are reported as a single field.
+// Items of keyed lists are compared with the item that has the same key and are referred to by the value of their
+// key field, so an item only in the template and one only in the CR never share a path.
+func diffFields(path keyPath, expected, actual any, keys listKeys) []FieldDiff {
+	switch exp := expected.(type) {
+	case map[string]any:
+		if act, ok := actual.(map[string]any); ok {
+			return diffMaps(path, exp, act, keys)
+		}
+	case []any:
+		if act, ok := actual.([]any); ok {
+			if key, keyed := keys.lookup(path); keyed {
+				return diffListsByKey(path, exp, act, key, keys)
+			}
+			return diffLists(path, exp, act, keys)
+		}
+	}
+	if valuesEqual(expected, actual) {
+		return nil
+	}
+	return []FieldDiff{newFieldDiff(path, expected, actual, FieldChanged)}
+}
+
+func diffMaps(path keyPath, expected, actual map[string]any, keys listKeys) []FieldDiff {
+	fields := make([]string, 0, len(expected)+len(actual))
+	for k := range expected {
+		fields = append(fields, k)
+	}
+	for k := range actual {
+		if _, ok := expected[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+
+	result := make([]FieldDiff, 0)
+	for _, k := range fields {
+		fieldPath := path.withKey(k)
+		exp, inExpected := expected[k]
+		act, inActual := actual[k]
+		switch {
+		case !inActual:
+			result = append(result, newFieldDiff(fieldPath, exp, nil, FieldMissing))
+		case !inExpected:
+			result = append(result, newFieldDiff(fieldPath, nil, act, FieldUnexpected))
+		default:
+			result = append(result, diffFields(fieldPath, exp, act, keys)...)
+		}
+	}
+	return result
+}
+
+func diffLists(path keyPath, expected, actual []any, keys listKeys) []FieldDiff {
+	result := make([]FieldDiff, 0)
+	for i := 0; i < max(len(expected), len(actual)); i++ {
+		fieldPath := path.withIndex(i)
+		switch {
+		case i >= len(actual):
+			result = append(result, newFieldDiff(fieldPath, expected[i], nil, FieldMissing))
+		case i >= len(expected):
+			result = append(result, newFieldDiff(fieldPath, nil, actual[i], FieldUnexpected))
+		default:
+			result = append(result, diffFields(fieldPath, expected[i], actual[i], keys)...)
+		}
+	}
+	return result
+}
+
+func diffListsByKey(path keyPath, expected, actual []any, key string, keys listKeys) []FieldDiff {
+	pairs := matchItemsByKey(actual, expected, key)
+	paired := make([]bool, len(expected))
+	result := make([]FieldDiff, 0)
+	for i, j := range pairs {
+		fieldPath := path.withItem(i, actual[i], key)
+		if j == -1 {
+			result = append(result, newFieldDiff(fieldPath, nil, actual[i], FieldUnexpected))
+			continue
+		}
+		paired[j] = true
+		result = append(result, diffFields(fieldPath, expected[j], actual[i], keys)...)
+	}
+	for j, item := range expected {
+		if !paired[j] {
+			result = append(result, newFieldDiff(path.withItem(j, item, key), item, nil, FieldMissing))
+		}
+	}
+	return result
+}
+
+// countDifferingFields returns the number of leaf fields that differ, a subtree that only exists on one side counts
+// for every field in it so a template missing a whole section doesn't score the same as one wrong value.
+func countDifferingFields(diffs []FieldDiff) int {
+	count := 0
+	for _, diff := range diffs {
+		switch diff.ChangeType {
+		case FieldMissing:
+			count += countLeafFields(diff.Expected)
+		case FieldUnexpected:
+			count += countLeafFields(diff.Actual)
+		default:
+			count += max(countLeafFields(diff.Expected), countLeafFields(diff.Actual))
+		}
+	}
+	return count
+}
+
+// countLeafFields returns the number of scalar fields in a value, empty maps and lists count as a single field
+func countLeafFields(value any) int {
+	count := 0
+	switch v := value.(type) {
+	case map[string]any:
+		for _, field := range v {
+			count += countLeafFields(field)
+		}
+	case []any:
+		for _, item := range v {
+			count += countLeafFields(item)
+		}
+	}
+	return max(count, 1)
+}
+
+// valuesEqual compares values by their serialized form, values such as int64(1) and float64(1)
+// are rendered the same in the diff output and so are equal.
+func valuesEqual(a, b any) bool {
+	aData, errA := json.Marshal(a)
+	bData, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(aData, bData)
+}
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected any
+		actual   any
+		keys     listKeys
+		want     []FieldDiff
+	}{
+		{
+			name:     "equal values",
+			expected: map[string]any{"a": map[string]any{"b": int64(1)}},
+			actual:   map[string]any{"a": map[string]any{"b": float64(1)}},
+			want:     []FieldDiff{},
+		},
+		{
+			name:     "changed, missing and unexpected fields",
+			expected: map[string]any{"a": "x", "b": "y"},
+			actual:   map[string]any{"a": "z", "c": "w"},
+			want: []FieldDiff{
+				{Path: "a", Expected: "x", Actual: "z", ChangeType: FieldChanged},
+				{Path: "b", Expected: "y", ChangeType: FieldMissing},
+				{Path: "c", Actual: "w", ChangeType: FieldUnexpected},
+			},
+		},
+		{
+			name:     "list items by index",
+			expected: map[string]any{"l": []any{"a", "b"}},
+			actual:   map[string]any{"l": []any{"a", "c", "d"}},
+			want: []FieldDiff{
+				{Path: "l[1]", Expected: "b", Actual: "c", ChangeType: FieldChanged},
+				{Path: "l[2]", Actual: "d", ChangeType: FieldUnexpected},
+			},
+		},
+		{
+			name: "keyed list items by key",
+			expected: map[string]any{"l": []any{
+				map[string]any{"name": "b", "v": "1"},
+				map[string]any{"name": "c", "v": "1"},
+			}},
+			actual: map[string]any{"l": []any{
+				map[string]any{"name": "a", "v": "1"},
+				map[string]any{"name": "b", "v": "2"},
+			}},
+			keys: newPathMatcher(map[string]string{"l": "name"}),
+			want: []FieldDiff{
+				{Path: `l[name="a"]`, Actual: map[string]any{"name": "a", "v": "1"}, ChangeType: FieldUnexpected},
+				{Path: `l[name="b"].v`, Expected: "1", Actual: "2", ChangeType: FieldChanged},
+				{Path: `l[name="c"]`, Expected: map[string]any{"name": "c", "v": "1"}, ChangeType: FieldMissing},
+			},
+		},
+		{
+			name: "keyed list items without the key field by index",
+			expected: map[string]any{"l": []any{
+				map[string]any{"port": int64(80)},
+			}},
+			actual: map[string]any{"l": []any{
+				map[string]any{"v": "1"},
+				map[string]any{"port": int64(80), "v": "1"},
+			}},
+			keys: newPathMatcher(map[string]string{"l": "port"}),
+			want: []FieldDiff{
+				{Path: "l[0]", Actual: map[string]any{"v": "1"}, ChangeType: FieldUnexpected},
+				{Path: "l[port=80].v", Actual: "1", ChangeType: FieldUnexpected},
+			},
+		},
+		{
+			name:     "keys with dots are quoted",
+			expected: map[string]any{"metadata": map[string]any{"labels": map[string]any{"k8s.io/app": "a"}}},
+			actual:   map[string]any{"metadata": map[string]any{"labels": map[string]any{"k8s.io/app": "b"}}},
+			want: []FieldDiff{
+				{Path: `metadata.labels."k8s.io/app"`, Expected: "a", Actual: "b", ChangeType: FieldChanged},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diffs := diffFields(nil, test.expected, test.actual, test.keys)
+			assert.Equal(t, test.want, diffs)
+			for _, diff := range diffs {
+				path, err := parsePathToKey(diff.Path)
+				require.NoError(t, err)
+				assert.Equal(t, diff.Path, path.String(), "paths of diffs round trip")
+			}
+		})
+	}
+}
+
+func TestFindBestMatchCountsLeafFields(t *testing.T) {
+	cr := map[string]any{"kind": "Deployment", "spec": map[string]any{"replicas": int64(1)}}
+	largeSpec := ReferenceTemplateV1{Path: "large.yaml"}
+	smallSpec := ReferenceTemplateV1{Path: "small.yaml"}
+	matches := []matchCounts{
+		{
+			temp: largeSpec,
+			fieldDiffs: diffFields(nil, map[string]any{"kind": "Deployment", "spec": map[string]any{"replicas": int64(1)},
+				"status": map[string]any{"replicas": int64(1), "readyReplicas": int64(1), "conditions": []any{"a", "b"}}}, cr, nil),
+		},
+		{
+			temp:       smallSpec,
+			fieldDiffs: diffFields(nil, map[string]any{"kind": "Deployment", "spec": map[string]any{"replicas": int64(1)}, "status": map[string]any{}}, cr, nil),
+		},
+	}
+	require.Len(t, matches[0].fieldDiffs, 1)
+	require.Len(t, matches[1].fieldDiffs, 1)
+
+	assert.Equal(t, 4, matches[0].differingFieldCount())
+	assert.Equal(t, 1, matches[1].differingFieldCount())
+	assert.Equal(t, "small.yaml", findBestMatch(matches).temp.GetIdentifier(), "a missing subtree counts for every field in it")
+}
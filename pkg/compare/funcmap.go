@@ -5,6 +5,8 @@ package compare
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"strings"
 	"text/template"
 
@@ -25,9 +27,11 @@ import (
 //
 //   - "include"
 //   - "tpl"
+//   - "lookup"
 //
-// These are late-bound in Engine.Render().  The
-// version included in the FuncMap is a placeholder.
+// include and tpl are bound to each parsed template by bindIncludeFuncs, lookup
+// is bound to the collected resources before the templates are compared to
+// them. The version included in the FuncMap is a placeholder.
 func FuncMap() template.FuncMap {
 	f := sprig.TxtFuncMap()
 	delete(f, "env")
@@ -42,6 +46,12 @@ func FuncMap() template.FuncMap {
 		"toJson":        toJSON,
 		"fromJson":      fromJSON,
 		"fromJsonArray": fromJSONArray,
+		"lookup":        emptyLookup,
+
+		// Placeholders for the late-bound functions, declaring them here lets
+		// templates that use them be parsed.
+		"include": func(string, any) string { return "not implemented" },
+		"tpl":     func(string, any) any { return "not implemented" },
 	}
 
 	for k, v := range extra {
@@ -51,6 +61,83 @@ func FuncMap() template.FuncMap {
 	return f
 }
 
+// includeMaxDepth is how many times a named template can be included within itself, as in Helm
+const includeMaxDepth = 1000
+
+// includeDepthError is returned when a named template is included within itself more than includeMaxDepth times
+type includeDepthError struct {
+	name string
+}
+
+func (e includeDepthError) Error() string {
+	return fmt.Sprintf("rendering template has a nested reference name: %s, it's included more than %d times within itself",
+		e.name, includeMaxDepth)
+}
+
+// unwrapIncludeDepthError returns the includeDepthError of an include or tpl call nested in err, so the error isn't
+// wrapped once by every level of the recursion.
+func unwrapIncludeDepthError(err error) error {
+	var depthErr includeDepthError
+	if errors.As(err, &depthErr) {
+		return depthErr
+	}
+	return err
+}
+
+// bindIncludeFuncs binds include and tpl to the template the way Helm binds them when rendering a chart. include
+// executes a named template of the template, or of the templateFunctionFiles, and returns its output so it can be
+// piped to other functions. tpl executes a string as a template that can include the same named templates.
+func bindIncludeFuncs(t *template.Template) {
+	bindIncludeFuncsWithNames(t, make(map[string]int))
+}
+
+// bindIncludeFuncsWithNames binds include and tpl to t, includedNames counts how deep each named template is
+// currently included so a template that includes itself fails instead of recursing forever. The counts aren't
+// guarded, every execution of a template binds the functions again with its own includedNames.
+func bindIncludeFuncsWithNames(t *template.Template, includedNames map[string]int) {
+	enter := func(name string) error {
+		if includedNames[name] >= includeMaxDepth {
+			return includeDepthError{name: name}
+		}
+		includedNames[name]++
+		return nil
+	}
+	t.Funcs(template.FuncMap{
+		"include": func(name string, data any) (string, error) {
+			if err := enter(name); err != nil {
+				return "", err
+			}
+			defer func() { includedNames[name]-- }()
+			var buf strings.Builder
+			if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+				return "", unwrapIncludeDepthError(err)
+			}
+			return buf.String(), nil
+		},
+		"tpl": func(text string, data any) (string, error) {
+			if err := enter(t.Name()); err != nil {
+				return "", err
+			}
+			defer func() { includedNames[t.Name()]-- }()
+			// The string is parsed in a copy of the template so the templates it defines don't leak into the template
+			clone, err := t.Clone()
+			if err != nil {
+				return "", fmt.Errorf("failed to copy template %s for tpl: %w", t.Name(), err)
+			}
+			bindIncludeFuncsWithNames(clone, includedNames)
+			parsed, err := clone.New(t.Name() + "/tpl").Parse(text)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse tpl string: %w", err)
+			}
+			var buf strings.Builder
+			if err := parsed.Execute(&buf, data); err != nil {
+				return "", unwrapIncludeDepthError(err)
+			}
+			return buf.String(), nil
+		},
+	})
+}
+
 // toYAML takes an interface, marshals it to yaml, and returns a string. It will
 // always return a string, even on marshal error (empty string).
 //
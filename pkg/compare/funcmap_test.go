@@ -0,0 +1,56 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncludeDepthIsCountedPerExecution(t *testing.T) {
+	const executions = 2
+	// Every execution waits at the bottom of its includes for the others, so together they include the template
+	// deeper than includeMaxDepth while each of them alone doesn't
+	var arrived sync.WaitGroup
+	arrived.Add(executions)
+	allArrived := make(chan struct{})
+	go func() {
+		arrived.Wait()
+		close(allArrived)
+	}()
+	barrier := func() string {
+		arrived.Done()
+		select {
+		case <-allArrived:
+		case <-time.After(5 * time.Second):
+		}
+		return ""
+	}
+
+	parsed, err := template.New("countdown.yaml").Funcs(FuncMap()).Funcs(template.FuncMap{"barrier": barrier}).Parse(`
+{{- define "countdown" }}{{ if gt . 0 }}{{ include "countdown" (sub . 1) }}{{ else }}{{ barrier }}{{ end }}{{ end -}}
+done: {{ include "countdown" .depth }}true
+`)
+	require.NoError(t, err)
+	bindIncludeFuncs(parsed)
+	temp := ReferenceTemplateV1{Template: parsed, Path: "countdown.yaml"}
+
+	var wg sync.WaitGroup
+	errs := make([]error, executions)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = temp.Exec(map[string]any{"depth": includeMaxDepth * 3 / 4})
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+}
@@ -85,7 +85,7 @@ func readHttpWithRetries(get httpget, duration time.Duration, u string, attempts
 			return nil, 0, fmt.Errorf("error occurred while attempting to close request body: %w", err)
 		}
 		// Error - Set the error condition from the StatusCode
-		err = fmt.Errorf("unable to read URL %q, server reported %s, status code=%d", u, status, statusCode)
+		err = httpStatusError{url: u, status: status, statusCode: statusCode}
 
 		if statusCode >= 500 && statusCode < 600 {
 			// Retry 500's
@@ -98,6 +98,21 @@ func readHttpWithRetries(get httpget, duration time.Duration, u string, attempts
 	return nil, 0, err
 }
 
+// httpStatusError is returned for urls the server didn't return, urls that aren't found are fs.ErrNotExist
+type httpStatusError struct {
+	url        string
+	status     string
+	statusCode int
+}
+
+func (e httpStatusError) Error() string {
+	return fmt.Sprintf("unable to read URL %q, server reported %s, status code=%d", e.url, e.status, e.statusCode)
+}
+
+func (e httpStatusError) Is(target error) bool {
+	return target == fs.ErrNotExist && e.statusCode == http.StatusNotFound
+}
+
 // HTTPFile represents a file obtained from an HTTP response body.
 type HTTPFile struct {
 	fi   HTTPFileInfo
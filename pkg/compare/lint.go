@@ -0,0 +1,645 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+	"k8s.io/utils/exec"
+)
+
+var (
+	lintLong = templates.LongDesc(`
+		Lint a reference configuration without comparing it to any cluster CRs.
+
+		Besides the errors that keep the reference from loading, lint reports templates that no correlation field
+		group can index, templates that are indexed by the same field values, per field config paths that don't exist
+		in their templates, fieldsToOmit items and template functions that no template uses, and components whose
+		groups contradict each other.
+
+		Fixture CRs can be passed like the CRs of the compare command. The fields the templates access that none of
+		the fixtures of their kind have are reported too, in templates with strictMissingKeys these fields fail every
+		CR like the fixtures.
+
+		Exit status: 0 No errors were found, there may be warnings. 1 Errors were found. >1 The reference or the
+		fixtures couldn't be read.
+	`)
+
+	lintExample = templates.Examples(`
+		# Lint a reference configuration:
+		kubectl cluster-compare lint -r ./reference/metadata.yaml
+
+		# Lint a reference configuration and check the fields its templates access against a set of fixture CRs:
+		kubectl cluster-compare lint -r ./reference/metadata.yaml -f ./fixtures -R
+
+		# Lint a reference configuration with JSON output:
+		kubectl cluster-compare lint -r ./reference/metadata.yaml -o json
+	`)
+)
+
+const LintErrorsFoundMsg = "the reference has lint errors"
+
+var LintOutputFormats = []string{Json}
+
+type LintSeverity string
+
+const (
+	LintError   LintSeverity = "error"
+	LintWarning LintSeverity = "warning"
+)
+
+// The checks lint issues are reported by
+const (
+	lintCheckLoad                 = "load"
+	lintCheckUncorrelated         = "uncorrelated-template"
+	lintCheckDuplicateCorrelation = "duplicate-correlation"
+	lintCheckFieldsToOmitRefs     = "fields-to-omit-refs"
+	lintCheckPerFieldPaths        = "per-field-paths"
+	lintCheckUnusedFieldsToOmit   = "unused-fields-to-omit"
+	lintCheckUnusedFunctions      = "unused-template-functions"
+	lintCheckComponentGroups      = "component-groups"
+	lintCheckUnprovidedFields     = "unprovided-fields"
+)
+
+type LintIssue struct {
+	Severity LintSeverity `json:"severity"`
+	Check    string       `json:"check"`
+	Template string       `json:"template,omitempty"`
+	Message  string       `json:"message"`
+}
+
+func (i LintIssue) String() string {
+	location := ""
+	if i.Template != "" {
+		location = i.Template + ": "
+	}
+	return fmt.Sprintf("%s: %s%s [%s]", i.Severity, location, i.Message, i.Check)
+}
+
+type LintReport struct {
+	Issues   []LintIssue `json:"issues"`
+	Errors   int         `json:"errors"`
+	Warnings int         `json:"warnings"`
+}
+
+func (r *LintReport) add(issues ...LintIssue) {
+	for _, issue := range issues {
+		r.Issues = append(r.Issues, issue)
+		if issue.Severity == LintError {
+			r.Errors++
+		} else {
+			r.Warnings++
+		}
+	}
+}
+
+func (r LintReport) String() string {
+	var sb strings.Builder
+	for _, issue := range r.Issues {
+		sb.WriteString(issue.String() + "\n")
+	}
+	sb.WriteString(fmt.Sprintf("%d errors, %d warnings\n", r.Errors, r.Warnings))
+	return sb.String()
+}
+
+func (r LintReport) Print(format string, out io.Writer) (int, error) {
+	var content []byte
+	switch format {
+	case Json:
+		var err error
+		content, err = json.Marshal(r)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal lint report to json: %w", err)
+		}
+		content = append(content, []byte("\n")...)
+	default:
+		content = []byte(r.String())
+	}
+	n, err := out.Write(content)
+	if err != nil {
+		return n, fmt.Errorf("error occurred when writing output: %w", err)
+	}
+	return n, nil
+}
+
+type LintOptions struct {
+	referenceConfig string
+	fixtures        resource.FilenameOptions
+	OutputFormat    string
+
+	builder *resource.Builder
+	genericiooptions.IOStreams
+}
+
+func NewLintOptions(ioStreams genericiooptions.IOStreams) *LintOptions {
+	return &LintOptions{IOStreams: ioStreams}
+}
+
+func NewLintCmd(f kcmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	options := NewLintOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:                   "lint -r <Reference File>",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Lint a reference configuration without comparing it to cluster CRs."),
+		Long:                  lintLong,
+		Example:               commandExample(lintExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckDiffErr(options.Complete(f, cmd, args))
+			// Like compare, errors found in the reference exit with 1 and failing to lint it exits with more
+			if err := options.Run(); err != nil {
+				if exitErr := diffError(err); exitErr != nil {
+					kcmdutil.CheckErr(kcmdutil.ErrExit)
+				}
+				kcmdutil.CheckDiffErr(err)
+			}
+		},
+	}
+
+	cmd.SetFlagErrorFunc(func(command *cobra.Command, err error) error {
+		kcmdutil.CheckDiffErr(kcmdutil.UsageErrorf(cmd, err.Error()))
+		return nil
+	})
+	cmd.Flags().StringVarP(&options.referenceConfig, "reference", "r", "", "Path to reference config file.")
+	kcmdutil.AddFilenameOptionFlags(cmd, &options.fixtures, "contains fixture CRs to check the fields accessed by the templates against")
+	cmd.Flags().StringVarP(&options.OutputFormat, "output", "o", "", fmt.Sprintf(`Output format. One of: (%s)`, strings.Join(LintOutputFormats, ", ")))
+	kcmdutil.CheckErr(cmd.RegisterFlagCompletionFunc(
+		"output",
+		func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			var comps []string
+			for _, format := range LintOutputFormats {
+				if strings.HasPrefix(format, toComplete) {
+					comps = append(comps, format)
+				}
+			}
+			return comps, cobra.ShellCompDirectiveNoFileComp
+		},
+	))
+
+	return cmd
+}
+
+func (o *LintOptions) Complete(f kcmdutil.Factory, cmd *cobra.Command, args []string) error {
+	o.builder = f.NewBuilder()
+	if len(args) != 0 {
+		return kcmdutil.UsageErrorf(cmd, "Unexpected args: %v", args)
+	}
+	if o.OutputFormat != "" && !slices.Contains(LintOutputFormats, o.OutputFormat) {
+		return kcmdutil.UsageErrorf(cmd, "Unsupported output format %s, supported: %s", o.OutputFormat, strings.Join(LintOutputFormats, ", "))
+	}
+	if o.referenceConfig == "" {
+		return kcmdutil.UsageErrorf(cmd, noRefFileWasPassed)
+	}
+	if _, err := os.Stat(o.referenceConfig); os.IsNotExist(err) && !isURL(o.referenceConfig) {
+		return fmt.Errorf(refFileNotExistsError)
+	}
+	return nil
+}
+
+func (o *LintOptions) Run() error {
+	cfs, err := GetRefFS(o.referenceConfig)
+	if err != nil {
+		return err
+	}
+	fixtures, err := o.readFixtures()
+	if err != nil {
+		return err
+	}
+	report := LintReference(cfs, filepath.Base(o.referenceConfig), fixtures)
+	if _, err := report.Print(o.OutputFormat, o.Out); err != nil {
+		return err
+	}
+	if report.Errors > 0 {
+		return exec.CodeExitError{Err: errors.New(LintErrorsFoundMsg), Code: 1}
+	}
+	return nil
+}
+
+func (o *LintOptions) readFixtures() ([]*unstructured.Unstructured, error) {
+	if len(o.fixtures.Filenames) == 0 && o.fixtures.Kustomize == "" {
+		return nil, nil
+	}
+	infos, err := o.builder.
+		Unstructured().
+		Local().
+		FilenameParam(false, &o.fixtures).
+		Flatten().
+		Do().
+		Infos()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+	fixtures := make([]*unstructured.Unstructured, 0, len(infos))
+	for _, info := range infos {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(info.Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture %s: %w", info.Source, err)
+		}
+		fixtures = append(fixtures, &unstructured.Unstructured{Object: obj})
+	}
+	return fixtures, nil
+}
+
+// LintReference loads the reference and reports the problems of the reference and its templates. The fields the
+// templates access are checked against the fixtures when any are given.
+func LintReference(fsys fs.FS, referenceFileName string, fixtures []*unstructured.Unstructured) LintReport {
+	report := LintReport{Issues: []LintIssue{}}
+	ref, err := GetReference(fsys, referenceFileName)
+	if err != nil {
+		report.add(LintIssue{Severity: LintError, Check: lintCheckLoad, Message: err.Error()})
+		return report
+	}
+	templates, loadErr := ParseTemplates(ref, fsys)
+
+	var issues []LintIssue
+	issues = append(issues, lintFieldsToOmitRefs(ref, templates)...)
+	issues = append(issues, lintPerFieldPaths(templates)...)
+	issues = append(issues, lintCorrelation(ref)...)
+	issues = append(issues, lintComponentGroups(ref)...)
+	issues = append(issues, lintUnusedFieldsToOmit(ref, templates)...)
+	issues = append(issues, lintUnusedFunctions(ref, fsys, templates)...)
+	if len(fixtures) > 0 {
+		issues = append(issues, lintUnprovidedFields(templates, fixtures)...)
+	}
+
+	// Load errors the checks already report with their template aren't repeated
+	reported := make(map[string]bool)
+	for _, issue := range issues {
+		reported[issue.Message] = true
+	}
+	for _, err := range flattenErrors(loadErr) {
+		if !reported[err.Error()] {
+			report.add(LintIssue{Severity: LintError, Check: lintCheckLoad, Message: err.Error()})
+		}
+	}
+	report.add(issues...)
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		return report.Issues[i].Severity == LintError && report.Issues[j].Severity != LintError
+	})
+	return report
+}
+
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	joined, isJoined := err.(interface{ Unwrap() []error })
+	if !isJoined {
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range joined.Unwrap() {
+		errs = append(errs, flattenErrors(e)...)
+	}
+	return errs
+}
+
+func lintFieldsToOmitRefs(ref Reference, templates []ReferenceTemplate) []LintIssue {
+	var issues []LintIssue
+	items := ref.GetFieldsToOmit().GetItems()
+	for _, temp := range templates {
+		for _, omitRef := range temp.GetConfig().GetFieldsToOmitRefs() {
+			if _, ok := items[omitRef]; !ok {
+				issues = append(issues, LintIssue{Severity: LintError, Check: lintCheckFieldsToOmitRefs,
+					Template: temp.GetPath(), Message: fmt.Sprintf(fieldsToOmitRefsNotFound, omitRef)})
+			}
+		}
+	}
+	return issues
+}
+
+// lintPerFieldPaths reports the per field config paths that point to no field of the template. Paths with wildcards
+// may legitimately match no field when the lists and maps they go through are templated, so they're only warned about.
+func lintPerFieldPaths(templates []ReferenceTemplate) []LintIssue {
+	var issues []LintIssue
+	for _, temp := range templates {
+		metadata := temp.GetMetadata()
+		if metadata == nil {
+			continue
+		}
+		config := temp.GetConfig()
+		var paths []string
+		for pathToKey := range config.GetInlineDiffFuncs() {
+			paths = append(paths, pathToKey)
+		}
+		for pathToKey := range config.GetListMergeKeys() {
+			paths = append(paths, pathToKey)
+		}
+		for pathToKey := range config.GetListTypes() {
+			paths = append(paths, pathToKey)
+		}
+		for pathToKey := range config.GetEmbeddedDocuments() {
+			paths = append(paths, pathToKey)
+		}
+		sort.Strings(paths)
+		paths = slices.Compact(paths)
+
+		object := metadata.DeepCopy().Object
+		decodeEmbeddedDocuments(object, newPathMatcher(config.GetEmbeddedDocuments()))
+		for _, pathToKey := range paths {
+			pattern, err := parsePathToKey(pathToKey)
+			if err != nil || len(pattern.expand(object)) > 0 {
+				continue
+			}
+			if pattern.hasWildcards() {
+				issues = append(issues, LintIssue{Severity: LintWarning, Check: lintCheckPerFieldPaths, Template: temp.GetPath(),
+					Message: fmt.Sprintf("config per field with pathToKey that matches no field in the template. path: %s", pathToKey)})
+				continue
+			}
+			issues = append(issues, LintIssue{Severity: LintError, Check: lintCheckPerFieldPaths, Template: temp.GetPath(),
+				Message: fmt.Sprintf(perFieldPathNotFound, pathToKey)})
+		}
+	}
+	return issues
+}
+
+// lintCorrelation reports the templates no field group of their correlations can index, these are only compared to
+// CRs correlated to them by the diff config, and the templates the same field group indexes by the same values.
+func lintCorrelation(ref Reference) []LintIssue {
+	var issues []LintIssue
+	claimed := make(map[string]bool)
+	var correlated []ReferenceTemplate
+	for _, correlation := range ref.GetFieldGroupCorrelations() {
+		// Templates listed by more than one component are only indexed once
+		templates := make([]ReferenceTemplate, 0, len(correlation.Templates))
+		for _, temp := range correlation.Templates {
+			if temp.GetMetadata() != nil && !slices.ContainsFunc(templates, func(t ReferenceTemplate) bool {
+				return t.GetIdentifier() == temp.GetIdentifier()
+			}) {
+				templates = append(templates, temp)
+			}
+		}
+		correlated = append(correlated, templates...)
+		correlators, unclaimed := claimFieldGroups(correlation.FieldGroups, templates)
+		for _, temp := range templates {
+			if !slices.Contains(unclaimed, temp) {
+				claimed[temp.GetIdentifier()] = true
+			}
+		}
+		for _, fc := range correlators {
+			keys := make([]string, 0, len(fc.objects))
+			for key := range fc.objects {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				if len(fc.objects[key]) > 1 {
+					issues = append(issues, LintIssue{Severity: LintWarning, Check: lintCheckDuplicateCorrelation,
+						Message: fmt.Sprintf("templates %s have the same %s (%s), CRs correlated to them are compared to "+
+							"the template with the least diffs", getTemplatesNames(fc.objects[key]), getFields(fc.Fields),
+							groupText(fc.objects[key][0].GetMetadata(), fc.Fields))})
+				}
+			}
+		}
+	}
+	reported := make(map[string]bool)
+	for _, temp := range correlated {
+		if !claimed[temp.GetIdentifier()] && !reported[temp.GetIdentifier()] {
+			reported[temp.GetIdentifier()] = true
+			issues = append(issues, LintIssue{Severity: LintWarning, Check: lintCheckUncorrelated, Template: temp.GetPath(),
+				Message: "every correlation field group has a field the template templates, only CRs correlated to it by the diff config are compared to it"})
+		}
+	}
+	return issues
+}
+
+// lintComponentGroups reports v2 components that require templates other components forbid with noneOf, and oneOf
+// groups that list a template more than once so matching it always matches more than one.
+func lintComponentGroups(ref Reference) []LintIssue {
+	refV2, ok := ref.(*ReferenceV2)
+	if !ok {
+		return nil
+	}
+	forbiddenBy := make(map[string]string)
+	for _, part := range refV2.Parts {
+		for _, comp := range part.Components {
+			for _, temp := range comp.NoneOf.templates {
+				forbiddenBy[temp.GetPath()] = comp.Name
+			}
+		}
+	}
+	var issues []LintIssue
+	for _, part := range refV2.Parts {
+		for _, comp := range part.Components {
+			for _, temp := range comp.AllOf.templates {
+				if forbidding, ok := forbiddenBy[temp.GetPath()]; ok {
+					issues = append(issues, LintIssue{Severity: LintError, Check: lintCheckComponentGroups, Template: temp.GetPath(),
+						Message: fmt.Sprintf("component %s requires the template with allOf but component %s forbids it with noneOf", comp.Name, forbidding)})
+				}
+			}
+			if len(comp.OneOf.templates) > 0 && !slices.ContainsFunc(comp.OneOf.templates, func(temp *ReferenceTemplateV2) bool {
+				_, forbidden := forbiddenBy[temp.GetPath()]
+				return !forbidden
+			}) {
+				issues = append(issues, LintIssue{Severity: LintError, Check: lintCheckComponentGroups,
+					Message: fmt.Sprintf("component %s requires one of its oneOf templates but other components forbid all of them with noneOf", comp.Name)})
+			}
+			if len(comp.AllOrNoneOf.templates) > 1 {
+				for _, temp := range comp.AllOrNoneOf.templates {
+					if forbidding, ok := forbiddenBy[temp.GetPath()]; ok {
+						issues = append(issues, LintIssue{Severity: LintWarning, Check: lintCheckComponentGroups, Template: temp.GetPath(),
+							Message: fmt.Sprintf("component %s requires the template with the rest of its allOrNoneOf templates but component %s "+
+								"forbids it with noneOf, so none of them can be matched", comp.Name, forbidding)})
+					}
+				}
+			}
+			for _, group := range []ComponentV2Group{&comp.OneOf, &comp.AnyOneOf} {
+				seen := make(map[string]bool)
+				for _, temp := range group.GetTemplates(part, comp) {
+					if seen[temp.GetPath()] {
+						issues = append(issues, LintIssue{Severity: LintError, Check: lintCheckComponentGroups, Template: temp.GetPath(),
+							Message: fmt.Sprintf("component %s lists the template more than once in %s, matching it always matches more than one",
+								comp.Name, getFieldNameFromStructTag(comp, group))})
+					}
+					seen[temp.GetPath()] = true
+				}
+			}
+		}
+	}
+	return issues
+}
+
+func lintUnusedFieldsToOmit(ref Reference, templates []ReferenceTemplate) []LintIssue {
+	toOmit := ref.GetFieldsToOmit()
+	includes := fieldsToOmitIncludes(toOmit)
+	used := make(map[string]bool)
+	queue := []string{toOmit.GetDefault()}
+	for _, temp := range templates {
+		queue = append(queue, temp.GetConfig().GetFieldsToOmitRefs()...)
+	}
+	for len(queue) > 0 {
+		key := queue[0]
+		queue = queue[1:]
+		if used[key] {
+			continue
+		}
+		used[key] = true
+		queue = append(queue, includes[key]...)
+	}
+
+	var unused []string
+	for key := range toOmit.GetItems() {
+		if !used[key] && key != builtInPathsKey {
+			unused = append(unused, key)
+		}
+	}
+	sort.Strings(unused)
+	var issues []LintIssue
+	for _, key := range unused {
+		issues = append(issues, LintIssue{Severity: LintWarning, Check: lintCheckUnusedFieldsToOmit,
+			Message: fmt.Sprintf("fieldsToOmit item %s isn't the default, referenced by any template or included by a used item", key)})
+	}
+	return issues
+}
+
+// fieldsToOmitIncludes returns the items each fieldsToOmit item includes, only v2 items can include others
+func fieldsToOmitIncludes(toOmit FieldsToOmit) map[string][]string {
+	includes := make(map[string][]string)
+	toOmitV2, ok := toOmit.(*FieldsToOmitV2)
+	if !ok {
+		return includes
+	}
+	for key, entries := range toOmitV2.Items {
+		for _, entry := range entries {
+			if entry.Include != "" {
+				includes[key] = append(includes[key], entry.Include)
+			}
+		}
+	}
+	return includes
+}
+
+// lintUnusedFunctions reports the templates defined in the templateFunctionFiles that no template invokes, directly
+// or through other named templates. Templates invoked by name only within strings passed to tpl can't be found.
+func lintUnusedFunctions(ref Reference, fsys fs.FS, templates []ReferenceTemplate) []LintIssue {
+	files := ref.GetTemplateFunctionFiles()
+	if len(files) == 0 {
+		return nil
+	}
+	functions, err := template.New("").Funcs(FuncMap()).ParseFS(ReferenceFS(ref, fsys), files...)
+	if err != nil {
+		// Function files that don't parse are reported by the templates
+		return nil
+	}
+	fileOf := make(map[string]string)
+	for _, file := range files {
+		fileOf[path.Base(file)] = file
+	}
+
+	used := make(map[string]bool)
+	for _, temp := range templates {
+		seen := make(map[string]bool)
+		queue := invokedTemplates(temp.GetTemplateTree())
+		for len(queue) > 0 {
+			name := queue[0]
+			queue = queue[1:]
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			used[name] = true
+			queue = append(queue, invokedTemplates(temp.lookupTemplateTree(name))...)
+		}
+	}
+
+	var issues []LintIssue
+	for _, function := range functions.Templates() {
+		name := function.Name()
+		if _, isFile := fileOf[name]; isFile || name == "" || used[name] || function.Tree == nil {
+			continue
+		}
+		issues = append(issues, LintIssue{Severity: LintWarning, Check: lintCheckUnusedFunctions,
+			Message: fmt.Sprintf("template function %s defined in %s isn't used by any template", name, fileOf[function.Tree.ParseName])})
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		return issues[i].Message < issues[j].Message
+	})
+	return issues
+}
+
+// invokedTemplates returns the names of the templates the tree invokes with the template action and the include function
+func invokedTemplates(tree *parse.Tree) []string {
+	if tree == nil || tree.Root == nil {
+		return nil
+	}
+	var names []string
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			if len(n.Args) > 1 {
+				function, isIdentifier := n.Args[0].(*parse.IdentifierNode)
+				name, isString := n.Args[1].(*parse.StringNode)
+				if isIdentifier && isString && function.Ident == "include" {
+					names = append(names, name.Text)
+				}
+			}
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			names = append(names, n.Name)
+			walk(n.Pipe)
+		}
+	}
+	walk(tree.Root)
+	return names
+}
+
+func lintUnprovidedFields(templates []ReferenceTemplate, fixtures []*unstructured.Unstructured) []LintIssue {
+	var issues []LintIssue
+	for _, access := range unprovidedFieldAccesses(templates, fixtures) {
+		issues = append(issues, LintIssue{Severity: LintWarning, Check: lintCheckUnprovidedFields, Template: access.Template,
+			Message: fmt.Sprintf("line %d accesses .%s that none of the fixtures of the template's kind have",
+				access.Line, strings.Join(access.Field, "."))})
+	}
+	return issues
+}
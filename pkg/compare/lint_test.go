@@ -0,0 +1,61 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/openshift/kube-compare/pkg/testutils"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+)
+
+const fixturesDirName = "fixtures"
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name         string
+		withFixtures bool
+		outputFormat string
+		exitCode     int
+	}{
+		{name: "Lint Clean Reference"},
+		{name: "Lint Reference With Issues", withFixtures: true, exitCode: 1},
+		{name: "Lint Reference With Issues", withFixtures: true, outputFormat: Json, exitCode: 1},
+		{name: "Lint Invalid Reference", exitCode: 1},
+	}
+	tf := cmdtesting.NewTestFactory()
+	defer tf.Cleanup()
+	for _, test := range tests {
+		golden := defaultOutSuffix
+		if test.outputFormat != "" {
+			golden = test.outputFormat + "_" + golden
+		}
+		t.Run(test.name+" "+golden, func(t *testing.T) {
+			testDir := path.Join(TestDirs, strings.ReplaceAll(test.name, " ", ""))
+			streams, _, out, _ := genericiooptions.NewTestIOStreams()
+			options := NewLintOptions(streams)
+			options.builder = tf.NewBuilder()
+			options.referenceConfig = path.Join(testDir, TestRefDirName, defaultReferenceFilename)
+			options.OutputFormat = test.outputFormat
+			if test.withFixtures {
+				options.fixtures = resource.FilenameOptions{Filenames: []string{path.Join(testDir, fixturesDirName)}, Recursive: true}
+			}
+
+			err := options.Run()
+			exitCode := 0
+			if err != nil {
+				exitErr := diffError(err)
+				require.NotNil(t, exitErr, "lint failed: %v", err)
+				exitCode = exitErr.ExitStatus()
+			}
+			require.Equal(t, test.exitCode, exitCode)
+			expected := testutils.GetFile(t, path.Join(testDir, golden), out.String(), *update)
+			require.Equal(t, expected, out.String())
+		})
+	}
+}
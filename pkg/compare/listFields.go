@@ -0,0 +1,225 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// listKeys maps lists, by their path in the pathToKey syntax, to the field that identifies their items.
+// Items of these lists are matched by the value of that field instead of by their position when merging and diffing.
+type listKeys = pathMatcher[string]
+
+type listType string
+
+const (
+	// listTypeSet the order and the number of occurrences of items in the list are ignored
+	listTypeSet listType = "set"
+	// listTypeMultiset the order of items in the list is ignored
+	listTypeMultiset listType = "multiset"
+)
+
+var listTypes = []listType{listTypeSet, listTypeMultiset}
+
+// unorderedLists maps lists, by their path in the pathToKey syntax, to how their items are compared.
+type unorderedLists = pathMatcher[listType]
+
+// itemKey returns an identity for a list item based on the value of its key field,
+// items that aren't objects or don't have the key field can't be identified.
+func itemKey(item any, key string) (string, bool) {
+	mapping, ok := item.(map[string]any)
+	if !ok {
+		return "", false
+	}
+	value, ok := mapping[key]
+	if !ok {
+		return "", false
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// matchItemsByKey pairs every item in from with the first unpaired item in to that has the same key.
+// The result holds for every item of from the index of its pair in to, or -1 if it has none.
+func matchItemsByKey(from, to []any, key string) []int {
+	unpaired := make(map[string][]int)
+	for i, item := range to {
+		if k, ok := itemKey(item, key); ok {
+			unpaired[k] = append(unpaired[k], i)
+		}
+	}
+	pairs := make([]int, len(from))
+	for i, item := range from {
+		pairs[i] = -1
+		k, ok := itemKey(item, key)
+		if !ok || len(unpaired[k]) == 0 {
+			continue
+		}
+		pairs[i] = unpaired[k][0]
+		unpaired[k] = unpaired[k][1:]
+	}
+	return pairs
+}
+
+// mergeValues applies patch onto current following the JSON merge patch rules (RFC 7386),
+// except for lists in keys whose items are merged with the item that has the same key. Nulls in the values the patch
+// adds are dropped like the nulls of maps merged into current, whatever the current value is.
+func mergeValues(path keyPath, current, patch any, keys listKeys) any {
+	patchMap, ok := patch.(map[string]any)
+	if !ok {
+		patchList, isList := patch.([]any)
+		currentList, currentIsList := current.([]any)
+		if isList && currentIsList {
+			if key, keyed := keys.lookup(path); keyed {
+				return mergeListByKey(path, currentList, patchList, key, keys)
+			}
+		}
+		return pruneNulls(patch)
+	}
+	currentMap, ok := current.(map[string]any)
+	if !ok {
+		return pruneNulls(patch)
+	}
+	for k, v := range patchMap {
+		if v == nil {
+			delete(currentMap, k)
+			continue
+		}
+		if c, ok := currentMap[k]; ok && c != nil {
+			currentMap[k] = mergeValues(path.withKey(k), c, v, keys)
+		} else {
+			currentMap[k] = pruneNulls(v)
+		}
+	}
+	return currentMap
+}
+
+// mergeListByKey keeps the order of the current list, items with a matching item in the patch are merged with it
+// and items only in the patch are added at the end.
+func mergeListByKey(path keyPath, current, patch []any, key string, keys listKeys) []any {
+	pairs := matchItemsByKey(current, patch, key)
+	paired := make([]bool, len(patch))
+	result := make([]any, 0, len(current)+len(patch))
+	for i, item := range current {
+		if pairs[i] == -1 {
+			result = append(result, item)
+			continue
+		}
+		paired[pairs[i]] = true
+		result = append(result, mergeValues(path.withIndex(i), item, patch[pairs[i]], keys))
+	}
+	for i, item := range patch {
+		if !paired[i] {
+			result = append(result, pruneNulls(item))
+		}
+	}
+	return result
+}
+
+func pruneNulls(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, item := range v {
+			if item == nil {
+				delete(v, k)
+			} else {
+				v[k] = pruneNulls(item)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			if item != nil {
+				v[i] = pruneNulls(item)
+			}
+		}
+	}
+	return value
+}
+
+// alignLists reorders the items of keyed lists in expected to follow the order of the matching items in actual,
+// items without a match are moved to the end. This way the diff only shows differences within the matched items.
+func alignLists(path keyPath, expected, actual any, keys listKeys) any {
+	switch exp := expected.(type) {
+	case map[string]any:
+		act, ok := actual.(map[string]any)
+		if !ok {
+			return expected
+		}
+		for k, v := range exp {
+			if a, ok := act[k]; ok {
+				exp[k] = alignLists(path.withKey(k), v, a, keys)
+			}
+		}
+		return exp
+	case []any:
+		act, ok := actual.([]any)
+		if !ok {
+			return expected
+		}
+		key, keyed := keys.lookup(path)
+		if !keyed {
+			for i := 0; i < min(len(exp), len(act)); i++ {
+				exp[i] = alignLists(path.withIndex(i), exp[i], act[i], keys)
+			}
+			return exp
+		}
+		pairs := matchItemsByKey(act, exp, key)
+		paired := make([]bool, len(exp))
+		result := make([]any, 0, len(exp))
+		for i, j := range pairs {
+			if j != -1 {
+				paired[j] = true
+				result = append(result, alignLists(path.withIndex(i), exp[j], act[i], keys))
+			}
+		}
+		for j, item := range exp {
+			if !paired[j] {
+				result = append(result, item)
+			}
+		}
+		return result
+	}
+	return expected
+}
+
+// sortLists puts the unordered lists within value into a canonical order, sorted by the serialized form of their
+// items, so two lists that only differ in their order end up the same. Duplicate items are dropped from sets.
+func sortLists(path keyPath, value any, lists unorderedLists) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, item := range v {
+			v[k] = sortLists(path.withKey(k), item, lists)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = sortLists(path.withIndex(i), item, lists)
+		}
+		t, ok := lists.lookup(path)
+		if !ok {
+			return v
+		}
+		serialized := make(map[string]any, len(v))
+		order := make([]string, 0, len(v))
+		for _, item := range v {
+			data, err := json.Marshal(item)
+			if err != nil {
+				return v
+			}
+			if _, seen := serialized[string(data)]; !seen || t == listTypeMultiset {
+				order = append(order, string(data))
+			}
+			serialized[string(data)] = item
+		}
+		sort.Strings(order)
+		result := make([]any, 0, len(order))
+		for _, data := range order {
+			result = append(result, serialized[data])
+		}
+		return result
+	}
+	return value
+}
@@ -0,0 +1,19 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeValuesDropsNulls(t *testing.T) {
+	patch := func() any {
+		return []any{map[string]any{"name": "a", "value": nil}}
+	}
+	expected := []any{map[string]any{"name": "a"}}
+	assert.Equal(t, expected, mergeValues(nil, map[string]any{"name": "b"}, patch(), nil), "list replacing a map")
+	assert.Equal(t, expected, mergeValues(nil, "b", patch(), nil), "list replacing a scalar")
+	assert.Equal(t, expected, mergeValues(nil, []any{"b"}, patch(), nil), "list replacing a list")
+}
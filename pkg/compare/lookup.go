@@ -0,0 +1,89 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"sort"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// resourceIndex holds the resources collected by the builder by apiVersion and kind, and then by namespace and name,
+// for the lookup template function.
+type resourceIndex struct {
+	byType map[string]map[string]*unstructured.Unstructured
+}
+
+func resourceTypeKey(apiVersion, kind string) string {
+	return strings.Join([]string{apiVersion, kind}, FieldSeparator)
+}
+
+func resourceNameKey(namespace, name string) string {
+	return strings.Join([]string{namespace, name}, FieldSeparator)
+}
+
+func newResourceIndex(infos []*resource.Info) *resourceIndex {
+	index := &resourceIndex{byType: make(map[string]map[string]*unstructured.Unstructured)}
+	for _, info := range infos {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(info.Object)
+		if err != nil {
+			continue
+		}
+		cr := &unstructured.Unstructured{Object: obj}
+		typeKey := resourceTypeKey(cr.GetAPIVersion(), cr.GetKind())
+		if index.byType[typeKey] == nil {
+			index.byType[typeKey] = make(map[string]*unstructured.Unstructured)
+		}
+		index.byType[typeKey][resourceNameKey(cr.GetNamespace(), cr.GetName())] = cr
+	}
+	return index
+}
+
+// lookup works like the lookup function of Helm. With a name it returns the resource or an empty map when it wasn't
+// collected. Without a name it returns a list of the resources of the kind in the namespace, or in all namespaces
+// when the namespace is empty too. The resources are copies, so templates can't change the compared resources.
+func (index *resourceIndex) lookup(apiVersion, kind, namespace, name string) (map[string]any, error) {
+	resources := index.byType[resourceTypeKey(apiVersion, kind)]
+	if name != "" {
+		cr, ok := resources[resourceNameKey(namespace, name)]
+		if !ok {
+			return map[string]any{}, nil
+		}
+		return runtime.DeepCopyJSON(cr.Object), nil
+	}
+
+	keys := make([]string, 0, len(resources))
+	for key, cr := range resources {
+		if namespace == "" || cr.GetNamespace() == namespace {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	items := make([]any, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, runtime.DeepCopyJSON(resources[key].Object))
+	}
+	return map[string]any{
+		"apiVersion": apiVersion,
+		"kind":       kind + "List",
+		"items":      items,
+	}, nil
+}
+
+// emptyLookup is the lookup function templates are parsed with, it finds no resources as there are none before the
+// resources are collected.
+func emptyLookup(apiVersion, kind, namespace, name string) (map[string]any, error) {
+	return (&resourceIndex{}).lookup(apiVersion, kind, namespace, name)
+}
+
+// bindLookup makes the lookup function of the templates read from the index
+func bindLookup(templates []ReferenceTemplate, index *resourceIndex) {
+	funcs := template.FuncMap{"lookup": index.lookup}
+	for _, temp := range templates {
+		temp.bindFuncs(funcs)
+	}
+}
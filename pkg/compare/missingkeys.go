@@ -0,0 +1,206 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+)
+
+// MissingKeyError is returned when a template with strictMissingKeys accesses a field that isn't in its data
+type MissingKeyError struct {
+	Template string
+	Line     int
+	Access   string
+	Key      string
+}
+
+func (e MissingKeyError) Error() string {
+	return fmt.Sprintf("%s:%d: map has no entry for key %q in %s", e.Template, e.Line, e.Key, e.Access)
+}
+
+// missingKeyMessage matches the errors text/template returns for missing keys when executed with missingkey=error
+var missingKeyMessage = regexp.MustCompile(`^template: ([^:]+):(\d+):\d+: executing "[^"]*" at <([^>]*)>: map has no entry for key "([^"]*)"`)
+
+// asMissingKeyError returns the MissingKeyError of a template execution error, or nil when the error is of
+// another kind. Errors in the template itself are attributed to the template path, errors in named templates
+// to the name of the file they're defined in.
+func asMissingKeyError(templatePath string, err error) error {
+	var execErr template.ExecError
+	if !errors.As(err, &execErr) {
+		return nil
+	}
+	// The error of a named template run by include is wrapped in the error of the include call, the key is missing
+	// in the innermost template
+	for {
+		var inner template.ExecError
+		if !errors.As(execErr.Unwrap(), &inner) {
+			break
+		}
+		execErr = inner
+	}
+	match := missingKeyMessage.FindStringSubmatch(execErr.Error())
+	if match == nil {
+		return nil
+	}
+	line, _ := strconv.Atoi(match[2])
+	file := match[1]
+	if file == path.Base(templatePath) {
+		file = templatePath
+	}
+	return MissingKeyError{Template: file, Line: line, Access: match[3], Key: match[4]}
+}
+
+// setMissingKeyOption sets how missing keys are handled in the template and every template associated with it,
+// as templates included from the templateFunctionFiles are executed with their own options.
+func setMissingKeyOption(t *template.Template, option string) {
+	for _, associated := range t.Templates() {
+		associated.Option("missingkey=" + option)
+	}
+}
+
+// warnMissingKeys prints the missing keys that kept a CR from being compared to templates with strictMissingKeys
+func warnMissingKeys(cr *unstructured.Unstructured, err error) {
+	joined, isJoined := err.(interface{ Unwrap() []error })
+	errs := []error{err}
+	if isJoined {
+		errs = joined.Unwrap()
+	}
+	for _, e := range errs {
+		var missingKey MissingKeyError
+		if errors.As(e, &missingKey) {
+			klog.Warningf("CR %s can't be compared to its template: %s", apiKindNamespaceName(cr), missingKey)
+		}
+	}
+}
+
+// FieldAccess is a field of the cluster CR a template accesses
+type FieldAccess struct {
+	Template string
+	Line     int
+	Field    []string
+}
+
+func (a FieldAccess) String() string {
+	return fmt.Sprintf("%s:%d: .%s", a.Template, a.Line, strings.Join(a.Field, "."))
+}
+
+// templateFieldAccesses returns the fields of the cluster CR the template accesses. Only fields accessed from the
+// top level data are known to belong to the CR, fields accessed within range and with blocks or in named templates
+// are relative to other data so they're skipped, and so are the values under ValuesKey.
+func templateFieldAccesses(temp ReferenceTemplate) []FieldAccess {
+	tree := temp.GetTemplateTree()
+	if tree == nil || tree.Root == nil {
+		return nil
+	}
+	var accesses []FieldAccess
+	add := func(node parse.Node, field []string) {
+		if len(field) == 0 || field[0] == ValuesKey {
+			return
+		}
+		location, _ := tree.ErrorContext(node)
+		line := 0
+		if parts := strings.Split(location, ":"); len(parts) > 1 {
+			line, _ = strconv.Atoi(parts[1])
+		}
+		accesses = append(accesses, FieldAccess{Template: temp.GetPath(), Line: line, Field: field})
+	}
+	var walk func(node parse.Node, rootDot bool)
+	walk = func(node parse.Node, rootDot bool) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child, rootDot)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe, rootDot)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd, rootDot)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg, rootDot)
+			}
+		case *parse.ChainNode:
+			walk(n.Node, rootDot)
+		case *parse.FieldNode:
+			if rootDot {
+				add(n, n.Ident)
+			}
+		case *parse.VariableNode:
+			if len(n.Ident) > 1 && n.Ident[0] == "$" {
+				add(n, n.Ident[1:])
+			}
+		case *parse.IfNode:
+			walk(n.Pipe, rootDot)
+			walk(n.List, rootDot)
+			walk(n.ElseList, rootDot)
+		case *parse.RangeNode:
+			walk(n.Pipe, rootDot)
+			walk(n.List, false)
+			walk(n.ElseList, rootDot)
+		case *parse.WithNode:
+			walk(n.Pipe, rootDot)
+			walk(n.List, false)
+			walk(n.ElseList, rootDot)
+		case *parse.TemplateNode:
+			walk(n.Pipe, rootDot)
+		}
+	}
+	walk(tree.Root, true)
+	return accesses
+}
+
+// unprovidedFieldAccesses returns the fields the templates access that none of the fixtures of their kind have,
+// with strictMissingKeys these accesses fail for every CR like the fixtures.
+func unprovidedFieldAccesses(templates []ReferenceTemplate, fixtures []*unstructured.Unstructured) []FieldAccess {
+	var result []FieldAccess
+	for _, temp := range templates {
+		var sameKind []*unstructured.Unstructured
+		for _, fixture := range fixtures {
+			if metadata := temp.GetMetadata(); metadata != nil &&
+				metadata.GetKind() == fixture.GetKind() && metadata.GetAPIVersion() == fixture.GetAPIVersion() {
+				sameKind = append(sameKind, fixture)
+			}
+		}
+		if len(sameKind) == 0 {
+			continue
+		}
+		for _, access := range templateFieldAccesses(temp) {
+			provided := false
+			for _, fixture := range sameKind {
+				if _, found, _ := unstructured.NestedFieldNoCopy(fixture.Object, access.Field...); found {
+					provided = true
+					break
+				}
+			}
+			if !provided {
+				result = append(result, access)
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Template != result[j].Template {
+			return result[i].Template < result[j].Template
+		}
+		return result[i].Line < result[j].Line
+	})
+	return result
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const fieldAccessTemplate = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .metadata.name }}
+  namespace: {{ $.metadata.namespace }}
+data:
+  {{- range .data.items }}
+  item: {{ .name }}
+  {{- end }}
+  {{- with .data.level }}
+  level: {{ .value }}
+  {{- end }}
+  mtu: "{{ .Values.mtu }}"
+`
+
+func TestUnprovidedFieldAccesses(t *testing.T) {
+	fsys := fstest.MapFS{
+		"metadata.yaml": {Data: []byte(`apiVersion: v2
+parts:
+  - name: Part
+    components:
+      - name: Component
+        allOf:
+          - path: cm.yaml
+`)},
+		"cm.yaml": {Data: []byte(fieldAccessTemplate)},
+	}
+	ref, err := GetReference(fsys, "metadata.yaml")
+	require.NoError(t, err)
+	templates, err := ParseTemplates(ref, fsys)
+	require.NoError(t, err)
+
+	var accessed []string
+	for _, access := range templateFieldAccesses(templates[0]) {
+		accessed = append(accessed, access.String())
+	}
+	assert.Equal(t, []string{
+		"cm.yaml:4: .metadata.name",
+		"cm.yaml:5: .metadata.namespace",
+		"cm.yaml:7: .data.items",
+		"cm.yaml:10: .data.level",
+	}, accessed)
+
+	fixtures := []*unstructured.Unstructured{
+		{Object: map[string]any{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]any{"name": "settings"},
+			"data":       map[string]any{"level": "debug"},
+		}},
+		{Object: map[string]any{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]any{"name": "settings", "namespace": "app"},
+		}},
+	}
+	var unprovided []string
+	for _, access := range unprovidedFieldAccesses(templates, fixtures) {
+		unprovided = append(unprovided, access.String())
+	}
+	assert.Equal(t, []string{
+		"cm.yaml:5: .metadata.namespace",
+		"cm.yaml:7: .data.items",
+	}, unprovided)
+
+	assert.Empty(t, unprovidedFieldAccesses(templates, fixtures[1:]), "templates without fixtures of their kind aren't checked")
+}
@@ -0,0 +1,61 @@
+package compare
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	numericRange inlineDiffType = "numericRange"
+)
+
+// NumericRangeInlineDiff accepts any number within the bounds declared in the template as min..max,
+// both bounds are inclusive and either one can be left out.
+type NumericRangeInlineDiff struct{}
+
+func parseNumericRange(templateValue string) (lower, upper float64, err error) {
+	minValue, maxValue, found := strings.Cut(strings.TrimSpace(templateValue), "..")
+	if !found {
+		return 0, 0, fmt.Errorf("range %q isn't in the format min..max", templateValue)
+	}
+	lower, upper = math.Inf(-1), math.Inf(1)
+	if minValue = strings.TrimSpace(minValue); minValue != "" {
+		if lower, err = strconv.ParseFloat(minValue, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid min of range %q: %w", templateValue, err)
+		}
+	}
+	if maxValue = strings.TrimSpace(maxValue); maxValue != "" {
+		if upper, err = strconv.ParseFloat(maxValue, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid max of range %q: %w", templateValue, err)
+		}
+	}
+	if lower > upper {
+		return 0, 0, fmt.Errorf("min of range %q is greater than its max", templateValue)
+	}
+	return lower, upper, nil
+}
+
+func (id NumericRangeInlineDiff) Diff(templateValue, crValue string) string {
+	lower, upper, err := parseNumericRange(templateValue)
+	if err != nil {
+		return templateValue
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(crValue), 64)
+	if err != nil || math.IsNaN(value) {
+		return templateValue
+	}
+	if value >= lower && value <= upper {
+		return crValue
+	}
+	return templateValue
+}
+
+func (id NumericRangeInlineDiff) Validate(templateValue string) error {
+	_, _, err := parseNumericRange(templateValue)
+	if err != nil {
+		return fmt.Errorf("invalid range passed to inline numericRange diff function: %w", err)
+	}
+	return nil
+}
@@ -20,17 +20,27 @@ import (
 
 // DiffSum Contains the diff output and correlation info of a specific CR
 type DiffSum struct {
-	DiffOutput         string   `json:"DiffOutput"`
-	CorrelatedTemplate string   `json:"CorrelatedTemplate"`
-	CRName             string   `json:"CRName"`
-	Patched            string   `json:"Patched,omitempty"`
-	OverrideReasons    []string `json:"OverrideReason,omitempty"`
-	Description        string   `json:"description,omitempty"`
+	DiffOutput         string                  `json:"DiffOutput"`
+	FieldDiffs         []FieldDiff             `json:"FieldDiffs,omitempty"`
+	CapturedValues     map[string][]string     `json:"CapturedValues,omitempty"`
+	CorrelatedTemplate string                  `json:"CorrelatedTemplate"`
+	CRName             string                  `json:"CRName"`
+	Patched            string                  `json:"Patched,omitempty"`
+	OverrideReasons    []string                `json:"OverrideReason,omitempty"`
+	Description        string                  `json:"description,omitempty"`
+	Explanation        *CorrelationExplanation `json:"Explanation,omitempty"`
+	// Owner is the CR in the ownerReferences of this CR that was matched to the owner template of its template
+	Owner string `json:"Owner,omitempty"`
+	// Children are the CRs this CR is the Owner of, their diffs come right after the diff of this CR
+	Children []string `json:"Children,omitempty"`
 }
 
 func (s DiffSum) String() string {
 	t := `
 Cluster CR: {{ .CRName }}
+{{- if .Owner }}
+Owner: {{ .Owner }}
+{{- end }}
 Reference File: {{ .CorrelatedTemplate }}
 {{- if .Description }}
 Description:
@@ -48,6 +58,10 @@ Patch Reasons:
 {{- end }}
 {{- end }}
 {{- end }}
+{{- if .Explanation }}
+Correlation:
+{{ .Explanation.String | indent 2 }}
+{{- end }}
 `
 	var buf bytes.Buffer
 	tmpl, _ := template.New("DiffSummary").Funcs(sprig.TxtFuncMap()).Parse(t)
@@ -65,22 +79,24 @@ func (s DiffSum) WasPatched() bool {
 
 // Summary Contains all info included in the Summary output of the compare command
 type Summary struct {
-	ValidationIssues map[string]map[string]ValidationIssue `json:"ValidationIssuses"`
-	NumMissing       int                                   `json:"NumMissing"`
-	UnmatchedCRS     []string                              `json:"UnmatchedCRS"`
-	NumDiffCRs       int                                   `json:"NumDiffCRs"`
-	TotalCRs         int                                   `json:"TotalCRs"`
-	MetadataHash     string                                `json:"MetadataHash"`
-	PatchedCRs       int                                   `json:"patchedCRs"`
+	ValidationIssues       map[string]map[string]ValidationIssue `json:"ValidationIssuses"`
+	NumMissing             int                                   `json:"NumMissing"`
+	UnmatchedCRS           []string                              `json:"UnmatchedCRS"`
+	NumDiffCRs             int                                   `json:"NumDiffCRs"`
+	TotalCRs               int                                   `json:"TotalCRs"`
+	MetadataHash           string                                `json:"MetadataHash"`
+	PatchedCRs             int                                   `json:"patchedCRs"`
+	UnmatchedCRSuggestions []UnmatchedCRSuggestions              `json:"UnmatchedCRSuggestions,omitempty"`
 }
 
-func newSummary(reference Reference, c *MetricsTracker, numDiffCRs int, templates []ReferenceTemplate, numPatchedCRs int) *Summary {
+func newSummary(reference Reference, c *MetricsTracker, numDiffCRs int, templates []ReferenceTemplate, values map[string]any, numPatchedCRs, numSuggestions int) *Summary {
 	s := Summary{NumDiffCRs: numDiffCRs, PatchedCRs: numPatchedCRs}
 	s.ValidationIssues, s.NumMissing = reference.GetValidationIssues(c.MatchedTemplatesNames)
 	s.TotalCRs = c.getTotalCRs()
 	s.UnmatchedCRS = lo.Map(c.UnMatchedCRs, func(r *unstructured.Unstructured, i int) string {
 		return apiKindNamespaceName(r)
 	})
+	s.UnmatchedCRSuggestions = suggestTemplates(c.UnMatchedCRs, templates, numSuggestions)
 
 	hash := sha256.New()
 
@@ -90,6 +106,15 @@ func newSummary(reference Reference, c *MetricsTracker, numDiffCRs int, template
 	}
 	hash.Write(refBytes)
 
+	// The same reference renders different templates with other values
+	if len(values) > 0 {
+		valuesBytes, err := yaml.Marshal(values)
+		if err != nil {
+			klog.Warning("There was an error in hashing the values, don't trust the hash")
+		}
+		hash.Write(valuesBytes)
+	}
+
 	for _, template := range templates {
 		for _, node := range template.GetTemplateTree().Root.Nodes {
 			hash.Write([]byte(node.String()))
@@ -128,6 +153,22 @@ No validation issues with the cluster
 {{- if ne (len  .UnmatchedCRS) 0 }}
 Cluster CRs unmatched to reference CRs: {{len  .UnmatchedCRS}}
 {{ toYaml .UnmatchedCRS}}
+{{- if ne (len .UnmatchedCRSuggestions) 0 }}
+Suggested templates for unmatched CRs:
+{{- range $cr := .UnmatchedCRSuggestions }}
+  {{ $cr.CRName }}:
+  {{- range $suggestion := $cr.Suggestions }}
+  - {{ $suggestion.Template }} (score {{ printf "%.2f" $suggestion.Score }})
+  {{- end }}
+{{- end }}
+To correlate the unmatched CRs to their best suggestion add the following to the diff config (-c flag):
+correlationSettings:
+  manualCorrelation:
+    correlationPairs:
+{{- range $cr := .UnmatchedCRSuggestions }}
+      {{ $cr.CorrelationPair }}
+{{- end }}
+{{- end }}
 {{- else}}
 No CRs are unmatched to reference CRs
 {{- end }}
@@ -146,9 +187,10 @@ No patched CRs
 
 // Output Contains the complete output of the command
 type Output struct {
-	Summary *Summary   `json:"Summary"`
-	Diffs   *[]DiffSum `json:"Diffs"`
-	patches []*UserOverride
+	Summary               *Summary               `json:"Summary"`
+	Diffs                 *[]DiffSum             `json:"Diffs"`
+	UnmatchedExplanations []UnmatchedExplanation `json:"UnmatchedExplanations,omitempty"`
+	patches               []*UserOverride
 }
 
 func (o Output) String(showEmptyDiffs bool) string {
@@ -157,13 +199,16 @@ func (o Output) String(showEmptyDiffs bool) string {
 	})
 
 	diffParts := []string{}
-
-	for _, diffSum := range *o.Diffs {
+	for _, diffSum := range nestOwned(*o.Diffs) {
 		if showEmptyDiffs || diffSum.HasDiff() || diffSum.WasPatched() {
 			diffParts = append(diffParts, fmt.Sprintln(diffSum.String()))
 		}
 	}
 
+	for _, explanation := range o.UnmatchedExplanations {
+		diffParts = append(diffParts, fmt.Sprintln(explanation.String()))
+	}
+
 	var str string
 	if len(diffParts) > 0 {
 		partsStr := strings.Join(diffParts, fmt.Sprintf("\n%s\n", DiffSeparator))
@@ -173,6 +218,48 @@ func (o Output) String(showEmptyDiffs bool) string {
 	return fmt.Sprintf("%s%s\n", str, o.Summary.String())
 }
 
+// nestOwned returns the diffs in the same order except for the diffs of CRs with an owner, that come right after the
+// diff of their owner. The owners list the CRs they own in their Children.
+func nestOwned(diffs []DiffSum) []DiffSum {
+	listed := make(map[string]bool)
+	for _, diffSum := range diffs {
+		listed[diffSum.CRName] = true
+	}
+	children := make(map[string][]DiffSum)
+	for _, diffSum := range diffs {
+		if diffSum.Owner != "" && listed[diffSum.Owner] {
+			children[diffSum.Owner] = append(children[diffSum.Owner], diffSum)
+		}
+	}
+	result := make([]DiffSum, 0, len(diffs))
+	var addDiff func(diffSum DiffSum)
+	addDiff = func(diffSum DiffSum) {
+		diffSum.Children = nil
+		for _, child := range children[diffSum.CRName] {
+			diffSum.Children = append(diffSum.Children, child.CRName)
+		}
+		result = append(result, diffSum)
+		for _, child := range children[diffSum.CRName] {
+			addDiff(child)
+		}
+	}
+	for _, diffSum := range diffs {
+		if diffSum.Owner == "" || !listed[diffSum.Owner] {
+			addDiff(diffSum)
+		}
+	}
+	return result
+}
+
+// structured returns the output as it's marshaled to JSON and YAML, with the owned CRs nested under their owners
+func (o Output) structured() Output {
+	if o.Diffs != nil {
+		diffs := nestOwned(*o.Diffs)
+		o.Diffs = &diffs
+	}
+	return o
+}
+
 func (o Output) Print(format string, out io.Writer, showEmptyDiffs bool) (int, error) {
 	var (
 		content []byte
@@ -180,14 +267,14 @@ func (o Output) Print(format string, out io.Writer, showEmptyDiffs bool) (int, e
 	)
 	switch format {
 	case Json:
-		content, err = json.Marshal(o)
+		content, err = json.Marshal(o.structured())
 		if err != nil {
 			return 0, fmt.Errorf("failed to marshal output to json: %w", err)
 		}
 		content = append(content, []byte("\n")...)
 
 	case Yaml:
-		content, err = yaml.Marshal(o)
+		content, err = yaml.Marshal(o.structured())
 		if err != nil {
 			return 0, fmt.Errorf("failed to marshal output to yaml: %w", err)
 		}
@@ -0,0 +1,118 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ownedMatch is a CR with a template that expects an owner among its candidates. It is matched once all other CRs
+// are matched, so it's known which template the CRs in its ownerReferences were matched to.
+type ownedMatch struct {
+	clusterCR     *unstructured.Unstructured
+	temps         []ReferenceTemplate
+	userOverrides []*UserOverride
+}
+
+func hasOwnerTemplateCandidate(temps []ReferenceTemplate) bool {
+	for _, temp := range temps {
+		if temp.GetConfig().GetOwnerTemplate() != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// matchedOwners records the template each CR was matched to, by the CR's UID and by its apiVersion_kind_namespace_name
+type matchedOwners struct {
+	lock  sync.Mutex
+	byKey map[string]matchedOwner
+}
+
+type matchedOwner struct {
+	crName   string
+	template string
+}
+
+func (m *matchedOwners) add(cr *unstructured.Unstructured, template string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.byKey == nil {
+		m.byKey = make(map[string]matchedOwner)
+	}
+	owner := matchedOwner{crName: apiKindNamespaceName(cr), template: template}
+	if uid := cr.GetUID(); uid != "" {
+		m.byKey["uid:"+string(uid)] = owner
+	}
+	m.byKey[owner.crName] = owner
+}
+
+// owners returns the matched CRs in the ownerReferences of the CR. Owners are looked up by UID and, as CRs read from
+// files often have no UID, by name in the namespace of the CR or at the cluster scope.
+func (m *matchedOwners) owners(cr *unstructured.Unstructured) []matchedOwner {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var result []matchedOwner
+	for _, ref := range cr.GetOwnerReferences() {
+		for _, key := range ownerKeys(cr, ref) {
+			if owner, ok := m.byKey[key]; ok {
+				result = append(result, owner)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func ownerKeys(cr *unstructured.Unstructured, ref metav1.OwnerReference) []string {
+	var keys []string
+	if ref.UID != "" {
+		keys = append(keys, "uid:"+string(ref.UID))
+	}
+	owner := &unstructured.Unstructured{}
+	owner.SetAPIVersion(ref.APIVersion)
+	owner.SetKind(ref.Kind)
+	owner.SetName(ref.Name)
+	if cr.GetNamespace() != "" {
+		owner.SetNamespace(cr.GetNamespace())
+		keys = append(keys, apiKindNamespaceName(owner))
+		owner.SetNamespace("")
+	}
+	return append(keys, apiKindNamespaceName(owner))
+}
+
+// filterByOwner drops the templates that expect an owner template none of the owners of the CR was matched to.
+// It returns the remaining templates, the owner of each of them that expects one, and whether any of them does.
+func (m *matchedOwners) filterByOwner(cr *unstructured.Unstructured, temps []ReferenceTemplate) ([]ReferenceTemplate, map[string]string, bool) {
+	owners := m.owners(cr)
+	result := make([]ReferenceTemplate, 0, len(temps))
+	ownerOf := make(map[string]string)
+	for _, temp := range temps {
+		expected := temp.GetConfig().GetOwnerTemplate()
+		if expected == "" {
+			result = append(result, temp)
+			continue
+		}
+		for _, owner := range owners {
+			if owner.template == expected {
+				result = append(result, temp)
+				ownerOf[temp.GetIdentifier()] = owner.crName
+				break
+			}
+		}
+	}
+	return result, ownerOf, len(ownerOf) > 0
+}
+
+func templatesWithoutOwner(temps []ReferenceTemplate) []ReferenceTemplate {
+	result := make([]ReferenceTemplate, 0, len(temps))
+	for _, temp := range temps {
+		if temp.GetConfig().GetOwnerTemplate() == "" {
+			result = append(result, temp)
+		}
+	}
+	return result
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"text/template"
 	"text/template/parse"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -20,6 +21,19 @@ type Reference interface {
 	GetValidationIssues(matchedTemplates map[string]int) (map[string]map[string]ValidationIssue, int)
 	GetFieldsToOmit() FieldsToOmit
 	GetTemplateFunctionFiles() []string
+	GetGlobalCaptureGroups() []string
+	GetFieldGroupCorrelations() []FieldGroupCorrelation
+	GetValues() map[string]any
+	GetValuesSchema() map[string]any
+}
+
+// FieldGroupCorrelation is a set of templates and the groups of fields they are correlated by,
+// the correlations of a reference are tried in order.
+type FieldGroupCorrelation struct {
+	FieldGroups [][][]string
+	Templates   []ReferenceTemplate
+	// custom is set for field groups declared by the reference
+	custom bool
 }
 
 type ReferenceTemplate interface {
@@ -31,12 +45,19 @@ type ReferenceTemplate interface {
 	GetConfig() TemplateConfig
 	GetTemplateTree() *parse.Tree
 	GetDescription() string
+	bindFuncs(funcs template.FuncMap)
+	lookupTemplateTree(name string) *parse.Tree
 }
 
 type TemplateConfig interface {
 	GetAllowMerge() bool
 	GetFieldsToOmitRefs() []string
 	GetInlineDiffFuncs() map[string]inlineDiffType
+	GetListMergeKeys() map[string]string
+	GetListTypes() map[string]listType
+	GetEmbeddedDocuments() map[string]documentFormat
+	GetSingleInstance() bool
+	GetOwnerTemplate() string
 }
 
 type FieldsToOmit interface {
@@ -95,13 +116,26 @@ type UserConfig struct {
 }
 
 type CorrelationSettings struct {
-	ManualCorrelation ManualCorrelation `json:"manualCorrelation"`
+	ManualCorrelation   ManualCorrelation   `json:"manualCorrelation"`
+	SelectorCorrelation SelectorCorrelation `json:"selectorCorrelation"`
 }
 
 type ManualCorrelation struct {
 	CorrelationPairs map[string]string `json:"correlationPairs"`
 }
 
+type SelectorCorrelation struct {
+	CorrelationSelectors []CorrelationSelector `json:"correlationSelectors"`
+}
+
+// CorrelationSelector maps the CRs selected by a label selector, an annotation selector or both to templates.
+// The selectors use the Kubernetes label selector syntax, for example "app=frontend,tier in (web,api)".
+type CorrelationSelector struct {
+	LabelSelector      string   `json:"labelSelector,omitempty"`
+	AnnotationSelector string   `json:"annotationSelector,omitempty"`
+	Templates          []string `json:"templates"`
+}
+
 func parseDiffConfig(filePath string) (UserConfig, error) {
 	result := UserConfig{}
 	confPath, err := filepath.Abs(filePath)
@@ -113,12 +147,18 @@ func parseDiffConfig(filePath string) (UserConfig, error) {
 }
 
 func ParseTemplates(ref Reference, fsys fs.FS) ([]ReferenceTemplate, error) {
+	return ParseTemplatesWithValues(ref, fsys, ref.GetValues())
+}
+
+// ParseTemplatesWithValues parses the templates of the reference, the metadata of the templates is extracted with
+// the given values of the reference parameters.
+func ParseTemplatesWithValues(ref Reference, fsys fs.FS, values map[string]any) ([]ReferenceTemplate, error) {
 	if strings.EqualFold(ref.GetAPIVersion(), ReferenceVersionV1) {
 		refV1 := ref.(*ReferenceV1)
-		return ParseV1Templates(refV1, fsys)
+		return ParseV1Templates(refV1, fsys, values)
 	} else if strings.EqualFold(ref.GetAPIVersion(), ReferenceVersionV2) {
 		refV2 := ref.(*ReferenceV2)
-		return ParseV2Templates(refV2, fsys)
+		return ParseV2Templates(refV2, fsys, values)
 	}
 
 	return nil, fmt.Errorf("unknown reference file apiVersion: '%s'", ref.GetAPIVersion())
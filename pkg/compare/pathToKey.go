@@ -0,0 +1,572 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type segmentKind int
+
+const (
+	// keySegment is a key of a map
+	keySegment segmentKind = iota
+	// globSegment matches the keys of a map that match a glob, where * matches any characters and ? a single one
+	globSegment
+	// indexSegment is an item of a list, written as [n]
+	indexSegment
+	// anyIndexSegment matches every item of a list, written as [*]
+	anyIndexSegment
+	// itemSegment is the item of a list with a key field set to a value, written as [field=value] with the value
+	// in JSON. Items of lists matched by key are referred to this way in diffs.
+	itemSegment
+)
+
+type pathSegment struct {
+	kind  segmentKind
+	key   string
+	index int
+	glob  *regexp.Regexp
+	// value is the serialized value of the key field of an itemSegment
+	value string
+}
+
+// keyPath is a parsed pathToKey. Paths taken from an object, for example the path of a field in a diff,
+// only contain keySegment, indexSegment and itemSegment segments, the itemSegment segments of these paths also
+// hold the index of the item.
+type keyPath []pathSegment
+
+func (p keyPath) withKey(key string) keyPath {
+	result := make(keyPath, 0, len(p)+1)
+	result = append(result, p...)
+	return append(result, pathSegment{kind: keySegment, key: key})
+}
+
+func (p keyPath) withIndex(index int) keyPath {
+	result := make(keyPath, 0, len(p)+1)
+	result = append(result, p...)
+	return append(result, pathSegment{kind: indexSegment, index: index})
+}
+
+// withItem returns the path of the item at index of a list matched by key, the item is referred to by the value of
+// its key field when it has one and by its index otherwise
+func (p keyPath) withItem(index int, item any, key string) keyPath {
+	value, ok := itemKey(item, key)
+	if !ok {
+		return p.withIndex(index)
+	}
+	result := make(keyPath, 0, len(p)+1)
+	result = append(result, p...)
+	return append(result, pathSegment{kind: itemSegment, key: key, value: value, index: index})
+}
+
+// isListItem reports whether the segment is a single item of a list
+func (s pathSegment) isListItem() bool {
+	return s.kind == indexSegment || s.kind == itemSegment
+}
+
+// parsePathToKey parses the pathToKey syntax: keys separated by dots, keys that contain dots are quoted,
+// keys may contain * and ? to match several keys and may be followed by list indices [n] or [*].
+func parsePathToKey(pathToKey string) (keyPath, error) {
+	s, _ := strings.CutPrefix(pathToKey, ".")
+	if s == "" {
+		return nil, errors.New("failed to parse path: path is empty")
+	}
+	result := make(keyPath, 0)
+	i := 0
+	for {
+		key, quoted, next, err := parseKey(s, i)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse path %q: %w", pathToKey, err)
+		}
+		i = next
+		if key == "" && !quoted && i < len(s) && s[i] == '[' {
+			return nil, fmt.Errorf("failed to parse path %q: list index without a key at position %d", pathToKey, i)
+		}
+		segment, err := newKeySegment(key, quoted)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse path %q: %w", pathToKey, err)
+		}
+		result = append(result, segment)
+
+		for i < len(s) && s[i] == '[' {
+			segment, next, err := parseListSegment(s, i)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse path %q: %w", pathToKey, err)
+			}
+			result = append(result, segment)
+			i = next
+		}
+
+		if i == len(s) {
+			return result, nil
+		}
+		if s[i] != '.' {
+			return nil, fmt.Errorf("failed to parse path %q: unexpected character %q at position %d", pathToKey, s[i], i)
+		}
+		i++
+	}
+}
+
+// parseKey reads the key that starts at position i, quoted keys use "" for a literal quote. Unquoted keys keep the
+// \ escapes so they can be read as globs, a \ escapes the next character, including dots.
+func parseKey(s string, i int) (key string, quoted bool, next int, err error) {
+	if i >= len(s) || s[i] != '"' {
+		start := i
+		for i < len(s) && s[i] != '.' && s[i] != '[' {
+			switch s[i] {
+			case '"':
+				return "", false, i, fmt.Errorf("bare \" in unquoted key at position %d", i)
+			case '\\':
+				if i+1 == len(s) {
+					return "", false, i, fmt.Errorf("trailing \\ in unquoted key at position %d", i)
+				}
+				i++
+			}
+			i++
+		}
+		return s[start:i], false, i, nil
+	}
+	var b strings.Builder
+	for i++; i < len(s); i++ {
+		if s[i] != '"' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '"' {
+			b.WriteByte('"')
+			i++
+			continue
+		}
+		return b.String(), true, i + 1, nil
+	}
+	return "", true, i, errors.New("unterminated quoted key")
+}
+
+// parseListSegment reads the list segment that starts with the [ at position i: an index [n], every item [*] or the
+// item with a key field set to a value [field=value]. The field is quoted like keys and the value is in JSON.
+func parseListSegment(s string, i int) (pathSegment, int, error) {
+	start := i
+	i++
+	var field string
+	if i < len(s) && s[i] == '"' {
+		key, _, next, err := parseKey(s, i)
+		if err != nil {
+			return pathSegment{}, next, err
+		}
+		if next >= len(s) || s[next] != '=' {
+			return pathSegment{}, next, fmt.Errorf("expected = after the key field at position %d", next)
+		}
+		field, i = key, next
+	} else {
+		end := strings.IndexAny(s[i:], "=]")
+		if end == -1 {
+			return pathSegment{}, i, fmt.Errorf("unterminated list index at position %d", start)
+		}
+		if s[i+end] == ']' {
+			index := s[i : i+end]
+			if index == "*" {
+				return pathSegment{kind: anyIndexSegment}, i + end + 1, nil
+			}
+			n, err := strconv.Atoi(index)
+			if err != nil || n < 0 {
+				return pathSegment{}, i, fmt.Errorf("invalid list index %q", index)
+			}
+			return pathSegment{kind: indexSegment, index: n}, i + end + 1, nil
+		}
+		field, i = s[i:i+end], i+end
+	}
+	decoder := json.NewDecoder(strings.NewReader(s[i+1:]))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return pathSegment{}, i, fmt.Errorf("invalid value of key field %q at position %d: %w", field, i+1, err)
+	}
+	end := i + 1 + int(decoder.InputOffset())
+	if end >= len(s) || s[end] != ']' {
+		return pathSegment{}, end, fmt.Errorf("unterminated list item at position %d", start)
+	}
+	serialized, err := json.Marshal(value)
+	if err != nil {
+		return pathSegment{}, end, fmt.Errorf("invalid value of key field %q: %w", field, err)
+	}
+	return pathSegment{kind: itemSegment, key: field, value: string(serialized), index: -1}, end + 1, nil
+}
+
+// newKeySegment returns the segment of a key, quoted keys are literal and unquoted keys with an unescaped * or ? are
+// globs
+func newKeySegment(key string, quoted bool) (pathSegment, error) {
+	if quoted {
+		return pathSegment{kind: keySegment, key: key}, nil
+	}
+	var literal strings.Builder
+	escaped := false
+	for _, r := range key {
+		switch {
+		case escaped:
+			literal.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '*' || r == '?':
+			glob, err := globToRegexp(key)
+			if err != nil {
+				return pathSegment{}, err
+			}
+			return pathSegment{kind: globSegment, key: key, glob: glob}, nil
+		default:
+			literal.WriteRune(r)
+		}
+	}
+	return pathSegment{kind: keySegment, key: literal.String()}, nil
+}
+
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	escaped := false
+	for _, r := range glob {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '*':
+			b.WriteString(".*")
+		case r == '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", glob, err)
+	}
+	return re, nil
+}
+
+// String returns the path in the pathToKey syntax
+func (p keyPath) String() string {
+	var b strings.Builder
+	for i, segment := range p {
+		switch segment.kind {
+		case indexSegment:
+			fmt.Fprintf(&b, "[%d]", segment.index)
+		case anyIndexSegment:
+			b.WriteString("[*]")
+		case itemSegment:
+			b.WriteString("[" + quoteKey(segment.key, `."[]=`) + "=" + segment.value + "]")
+		case globSegment:
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			// Globs are unquoted, their special characters are escaped
+			b.WriteString(segment.key)
+		default:
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(quoteKey(segment.key, `."[]*?\`))
+		}
+	}
+	return b.String()
+}
+
+// quoteKey quotes keys that are empty or contain one of the special characters, quoted keys are read literally
+func quoteKey(key, special string) string {
+	if key == "" || strings.ContainsAny(key, special) {
+		return `"` + strings.ReplaceAll(key, `"`, `""`) + `"`
+	}
+	return key
+}
+
+// matchesItem reports whether item is a map with the key field of the itemSegment s set to its value
+func (s pathSegment) matchesItem(item any) bool {
+	value, ok := itemKey(item, s.key)
+	return ok && value == s.value
+}
+
+// itemIndex returns the index of the first item of list matched by the itemSegment s, or -1 if there's none
+func (s pathSegment) itemIndex(list []any) int {
+	for i, item := range list {
+		if s.matchesItem(item) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s pathSegment) matches(concrete pathSegment) bool {
+	switch s.kind {
+	case keySegment:
+		return concrete.kind == keySegment && concrete.key == s.key
+	case globSegment:
+		return concrete.kind == keySegment && s.glob.MatchString(concrete.key)
+	case indexSegment:
+		return concrete.isListItem() && concrete.index == s.index
+	case anyIndexSegment:
+		return concrete.isListItem()
+	case itemSegment:
+		return concrete.kind == itemSegment && concrete.key == s.key && concrete.value == s.value
+	}
+	return false
+}
+
+// hasWildcards reports whether p can match more than one field
+func (p keyPath) hasWildcards() bool {
+	for _, segment := range p {
+		if segment.kind == globSegment || segment.kind == anyIndexSegment || segment.kind == itemSegment {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the path of a field taken from an object is matched by the pattern p
+func (p keyPath) matches(concrete keyPath) bool {
+	if len(p) != len(concrete) {
+		return false
+	}
+	for i := range p {
+		if !p[i].matches(concrete[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// expand returns the paths of the fields within object that are matched by p
+func (p keyPath) expand(object any) []keyPath {
+	paths := []keyPath{{}}
+	values := []any{object}
+	for _, segment := range p {
+		nextPaths := make([]keyPath, 0, len(paths))
+		nextValues := make([]any, 0, len(values))
+		for i, value := range values {
+			switch segment.kind {
+			case keySegment:
+				if mapping, ok := value.(map[string]any); ok {
+					if v, ok := mapping[segment.key]; ok {
+						nextPaths = append(nextPaths, paths[i].withKey(segment.key))
+						nextValues = append(nextValues, v)
+					}
+				}
+			case globSegment:
+				if mapping, ok := value.(map[string]any); ok {
+					keys := make([]string, 0)
+					for k := range mapping {
+						if segment.glob.MatchString(k) {
+							keys = append(keys, k)
+						}
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						nextPaths = append(nextPaths, paths[i].withKey(k))
+						nextValues = append(nextValues, mapping[k])
+					}
+				}
+			case indexSegment:
+				if list, ok := value.([]any); ok && segment.index < len(list) {
+					nextPaths = append(nextPaths, paths[i].withIndex(segment.index))
+					nextValues = append(nextValues, list[segment.index])
+				}
+			case anyIndexSegment:
+				if list, ok := value.([]any); ok {
+					for j, v := range list {
+						nextPaths = append(nextPaths, paths[i].withIndex(j))
+						nextValues = append(nextValues, v)
+					}
+				}
+			case itemSegment:
+				if list, ok := value.([]any); ok {
+					for j, v := range list {
+						if segment.matchesItem(v) {
+							nextPaths = append(nextPaths, paths[i].withItem(j, v, segment.key))
+							nextValues = append(nextValues, v)
+						}
+					}
+				}
+			}
+		}
+		paths, values = nextPaths, nextValues
+	}
+	return paths
+}
+
+// get returns the value of the field at the concrete path p
+func (p keyPath) get(object any) (any, bool) {
+	value := object
+	for _, segment := range p {
+		switch segment.kind {
+		case keySegment:
+			mapping, ok := value.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if value, ok = mapping[segment.key]; !ok {
+				return nil, false
+			}
+		case indexSegment, itemSegment:
+			list, ok := value.([]any)
+			if !ok {
+				return nil, false
+			}
+			index := segment.listIndex(list)
+			if index < 0 || index >= len(list) {
+				return nil, false
+			}
+			value = list[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// listIndex returns the index within list of the item the segment points to, itemSegment segments of paths taken
+// from an object hold the index of their item
+func (s pathSegment) listIndex(list []any) int {
+	if s.kind == indexSegment || s.index >= 0 && s.index < len(list) && s.matchesItem(list[s.index]) {
+		return s.index
+	}
+	return s.itemIndex(list)
+}
+
+// set updates the value of the field at the concrete path p, the parent of the field must exist
+func (p keyPath) set(object, value any) error {
+	if len(p) == 0 {
+		return errors.New("can't set the value of an empty path")
+	}
+	parent, ok := p[:len(p)-1].get(object)
+	if !ok {
+		return fmt.Errorf("parent of %s does not exist", p)
+	}
+	last := p[len(p)-1]
+	switch container := parent.(type) {
+	case map[string]any:
+		if last.kind == keySegment {
+			container[last.key] = value
+			return nil
+		}
+	case []any:
+		if index := last.listIndex(container); last.isListItem() && index >= 0 && index < len(container) {
+			container[index] = value
+			return nil
+		}
+	}
+	return fmt.Errorf("parent of %s is a %T", p, parent)
+}
+
+// removedListItem marks list items that are removed so the indices of the other items stay valid until all
+// fields are removed, the lists are then compacted with dropRemovedListItems.
+type removedListItem struct{}
+
+func (p keyPath) remove(object any) {
+	if len(p) == 0 {
+		return
+	}
+	parent, ok := p[:len(p)-1].get(object)
+	if !ok {
+		return
+	}
+	last := p[len(p)-1]
+	switch container := parent.(type) {
+	case map[string]any:
+		if last.kind == keySegment {
+			delete(container, last.key)
+		}
+	case []any:
+		if index := last.listIndex(container); last.isListItem() && index >= 0 && index < len(container) {
+			container[index] = removedListItem{}
+		}
+	}
+}
+
+func dropRemovedListItems(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, item := range v {
+			v[k] = dropRemovedListItems(item)
+		}
+	case []any:
+		result := make([]any, 0, len(v))
+		for _, item := range v {
+			if _, removed := item.(removedListItem); !removed {
+				result = append(result, dropRemovedListItems(item))
+			}
+		}
+		return result
+	}
+	return value
+}
+
+// pathMatcher looks up the perField config that applies to the path of a field
+type pathMatcher[T any] []pathMatcherEntry[T]
+
+type pathMatcherEntry[T any] struct {
+	path  keyPath
+	value T
+}
+
+func newPathMatcher[T any](perField map[string]T) pathMatcher[T] {
+	result := make(pathMatcher[T], 0, len(perField))
+	for pathToKey, value := range perField {
+		path, err := parsePathToKey(pathToKey)
+		if err != nil {
+			// The paths are validated when the reference is parsed
+			continue
+		}
+		result = append(result, pathMatcherEntry[T]{path: path, value: value})
+	}
+	// Sort so the most specific path is the first match when several paths match a field
+	sort.Slice(result, func(i, j int) bool {
+		if order := compareSpecificity(result[i].path, result[j].path); order != 0 {
+			return order < 0
+		}
+		return result[i].path.String() < result[j].path.String()
+	})
+	return result
+}
+
+// compareSpecificity orders paths segment by segment: keys before globs, and list indices or items before [*].
+// Globs with more literal characters come before other globs.
+func compareSpecificity(a, b keyPath) int {
+	for i := 0; i < min(len(a), len(b)); i++ {
+		if order := segmentSpecificity(a[i]) - segmentSpecificity(b[i]); order != 0 {
+			return order
+		}
+	}
+	return len(a) - len(b)
+}
+
+// segmentSpecificity ranks segments, lower is more specific
+func segmentSpecificity(s pathSegment) int {
+	switch s.kind {
+	case globSegment:
+		// Globs with more literal characters match fewer keys
+		return math.MaxInt32 - len(strings.NewReplacer("*", "", "?", "").Replace(s.key))
+	case anyIndexSegment:
+		return 1
+	}
+	return 0
+}
+
+func (m pathMatcher[T]) lookup(path keyPath) (T, bool) {
+	for _, entry := range m {
+		if entry.path.matches(path) {
+			return entry.value, true
+		}
+	}
+	var zero T
+	return zero, false
+}
@@ -0,0 +1,154 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePathToKey(t *testing.T) {
+	tests := []struct {
+		pathToKey string
+		canonical string
+		err       bool
+	}{
+		{pathToKey: "spec.replicas", canonical: "spec.replicas"},
+		{pathToKey: ".spec.replicas", canonical: "spec.replicas"},
+		{pathToKey: `metadata.annotations."k8s.io/app"`, canonical: `metadata.annotations."k8s.io/app"`},
+		{pathToKey: `"metadata"."labels"`, canonical: "metadata.labels"},
+		{pathToKey: `data."say ""hi"""`, canonical: `data."say ""hi"""`},
+		{pathToKey: "spec.containers[0].image", canonical: "spec.containers[0].image"},
+		{pathToKey: "spec.containers[*].env[*].value", canonical: "spec.containers[*].env[*].value"},
+		{pathToKey: "matrix[1][*]", canonical: "matrix[1][*]"},
+		{pathToKey: `metadata.annotations."*.openshift.io/*"`, canonical: `metadata.annotations."*.openshift.io/*"`},
+		{pathToKey: `metadata.annotations.*\.openshift\.io/*`, canonical: `metadata.annotations.*\.openshift\.io/*`},
+		{pathToKey: `data.a\*b`, canonical: `data."a*b"`},
+		{pathToKey: `data.a\.b`, canonical: `data."a.b"`},
+		{pathToKey: `data.a\`, err: true},
+		{pathToKey: "spec.containers.0", canonical: "spec.containers.0"},
+		{pathToKey: `spec.containers[name="app"].image`, canonical: `spec.containers[name="app"].image`},
+		{pathToKey: `spec.ports[port=80][*]`, canonical: `spec.ports[port=80][*]`},
+		{pathToKey: `l["a.b"="x]y"]`, canonical: `l["a.b"="x]y"]`},
+		{pathToKey: `l["name"=true]`, canonical: `l[name=true]`},
+		{pathToKey: `spec.containers[name=app]`, err: true},
+		{pathToKey: `spec.containers[name="app"`, err: true},
+		{pathToKey: "", err: true},
+		{pathToKey: "spec.containers[", err: true},
+		{pathToKey: "spec.containers[-1]", err: true},
+		{pathToKey: "spec.containers[a]", err: true},
+		{pathToKey: "spec.[0]", err: true},
+		{pathToKey: `spec."unterminated`, err: true},
+		{pathToKey: `spec."quoted"key`, err: true},
+		{pathToKey: `spec.bare"quote`, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.pathToKey, func(t *testing.T) {
+			path, err := parsePathToKey(test.pathToKey)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.canonical, path.String())
+		})
+	}
+}
+
+func TestKeyPathExpand(t *testing.T) {
+	object := map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]any{
+				"a.openshift.io/x": "1",
+				"b.openshift.io/y": "2",
+				"kubernetes.io/z":  "3",
+			},
+		},
+		"spec": map[string]any{
+			"containers": []any{
+				map[string]any{"name": "a", "image": "a:1"},
+				map[string]any{"name": "b"},
+				map[string]any{"name": "c", "image": "c:1"},
+			},
+		},
+	}
+	tests := []struct {
+		pathToKey string
+		expected  []string
+	}{
+		{"spec.containers[*].image", []string{"spec.containers[0].image", "spec.containers[2].image"}},
+		{"spec.containers[1].name", []string{"spec.containers[1].name"}},
+		{`spec.containers[name="c"].image`, []string{`spec.containers[name="c"].image`}},
+		{`spec.containers[name="d"].image`, []string{}},
+		{"spec.containers[3].name", []string{}},
+		{`metadata.annotations.*\.openshift\.io/*`, []string{`metadata.annotations."a.openshift.io/x"`, `metadata.annotations."b.openshift.io/y"`}},
+		{`metadata.annotations."*.openshift.io/*"`, []string{}},
+		{"metadata.annotations.kubernetes.io?z", []string{}},
+		{`metadata.annotations.kubernetes\.io?z`, []string{`metadata.annotations."kubernetes.io/z"`}},
+		{"spec.missing", []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.pathToKey, func(t *testing.T) {
+			pattern, err := parsePathToKey(test.pathToKey)
+			require.NoError(t, err)
+			result := make([]string, 0)
+			for _, path := range pattern.expand(object) {
+				assert.True(t, pattern.matches(path), path.String())
+				result = append(result, path.String())
+			}
+			assert.Equal(t, test.expected, result)
+		})
+	}
+}
+
+func TestPathMatcherPrefersSpecificPaths(t *testing.T) {
+	matcher := newPathMatcher(map[string]string{
+		"spec.*":             "glob",
+		"spec.f*":            "longer glob",
+		"spec.foo":           "key",
+		"spec.list[*].value": "any item",
+		"spec.list[1].value": "item",
+	})
+	tests := map[string]string{
+		"spec.foo":           "key",
+		"spec.far":           "longer glob",
+		"spec.bar":           "glob",
+		"spec.list[1].value": "item",
+		"spec.list[0].value": "any item",
+	}
+	for pathToKey, expected := range tests {
+		path, err := parsePathToKey(pathToKey)
+		require.NoError(t, err)
+		value, ok := matcher.lookup(path)
+		assert.True(t, ok, pathToKey)
+		assert.Equal(t, expected, value, pathToKey)
+	}
+}
+
+func TestOmitFieldsWithListItems(t *testing.T) {
+	object := map[string]any{
+		"spec": map[string]any{
+			"tolerations": []any{"a", "b", "c", "d"},
+			"containers": []any{
+				map[string]any{"name": "a", "image": "a:1"},
+			},
+		},
+	}
+	fields := make([]*ManifestPathV1, 0)
+	for _, pathToKey := range []string{"spec.tolerations[1]", "spec.tolerations[2]", "spec.containers[*].image"} {
+		field := &ManifestPathV1{PathToKey: pathToKey}
+		require.NoError(t, field.Process())
+		fields = append(fields, field)
+	}
+	omitFields(object, fields)
+	assert.Equal(t, map[string]any{
+		"spec": map[string]any{
+			"tolerations": []any{"a", "d"},
+			"containers": []any{
+				map[string]any{"name": "a"},
+			},
+		},
+	}, object)
+}
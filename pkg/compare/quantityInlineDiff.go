@@ -0,0 +1,37 @@
+package compare
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	quantity inlineDiffType = "quantity"
+)
+
+// QuantityInlineDiff compares fields as Kubernetes quantities, so 1Gi and 1024Mi or 500m and 0.5 are equal.
+type QuantityInlineDiff struct{}
+
+func (id QuantityInlineDiff) Diff(templateValue, crValue string) string {
+	expected, err := resource.ParseQuantity(templateValue)
+	if err != nil {
+		return templateValue
+	}
+	actual, err := resource.ParseQuantity(crValue)
+	if err != nil {
+		return templateValue
+	}
+	if expected.Cmp(actual) == 0 {
+		return crValue
+	}
+	return templateValue
+}
+
+func (id QuantityInlineDiff) Validate(templateValue string) error {
+	_, err := resource.ParseQuantity(templateValue)
+	if err != nil {
+		return fmt.Errorf("invalid quantity passed to inline quantity diff function: %w", err)
+	}
+	return nil
+}
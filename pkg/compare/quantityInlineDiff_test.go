@@ -0,0 +1,55 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuantityInlineDiff(t *testing.T) {
+	tests := []struct {
+		template string
+		cr       string
+		expected string
+	}{
+		{"1Gi", "1024Mi", "1024Mi"},
+		{"500m", "0.5", "0.5"},
+		{"1", "1000m", "1000m"},
+		{"1G", "1Gi", "1G"},
+		{"1Gi", "a lot", "1Gi"},
+	}
+	for _, test := range tests {
+		t.Run(test.template+" "+test.cr, func(t *testing.T) {
+			assert.Equal(t, test.expected, QuantityInlineDiff{}.Diff(test.template, test.cr))
+		})
+	}
+	assert.NoError(t, QuantityInlineDiff{}.Validate("100Mi"))
+	assert.Error(t, QuantityInlineDiff{}.Validate("100 MiB"))
+}
+
+func TestNumericRangeInlineDiff(t *testing.T) {
+	tests := []struct {
+		template string
+		cr       string
+		expected string
+	}{
+		{"1..10", "1", "1"},
+		{"1..10", "10", "10"},
+		{"1..10", "10.5", "1..10"},
+		{"0.5..", "1000", "1000"},
+		{"..-1", "-5", "-5"},
+		{"..-1", "0", "..-1"},
+		{"1..10", "five", "1..10"},
+	}
+	for _, test := range tests {
+		t.Run(test.template+" "+test.cr, func(t *testing.T) {
+			assert.Equal(t, test.expected, NumericRangeInlineDiff{}.Diff(test.template, test.cr))
+		})
+	}
+	for _, valid := range []string{"1..10", "..10", "1..", "..", "-2.5..-1"} {
+		assert.NoError(t, NumericRangeInlineDiff{}.Validate(valid), valid)
+	}
+	for _, invalid := range []string{"10", "10..1", "a..b", "1...2"} {
+		assert.Error(t, NumericRangeInlineDiff{}.Validate(invalid), invalid)
+	}
+}
@@ -4,12 +4,11 @@ package compare
 
 import (
 	"bytes"
-	"encoding/csv"
 	"errors"
 	"fmt"
 	"io/fs"
 	"path"
-	"strings"
+	"regexp"
 	"text/template"
 	"text/template/parse"
 
@@ -80,6 +79,23 @@ func (r *ReferenceV1) GetTemplateFunctionFiles() []string {
 	return r.TemplateFunctionFiles
 }
 
+func (r *ReferenceV1) GetGlobalCaptureGroups() []string {
+	return nil
+}
+
+// GetValues returns nil as v1 references can't declare default values, values can still be given with --values
+func (r *ReferenceV1) GetValues() map[string]any {
+	return nil
+}
+
+func (r *ReferenceV1) GetValuesSchema() map[string]any {
+	return nil
+}
+
+func (r *ReferenceV1) GetFieldGroupCorrelations() []FieldGroupCorrelation {
+	return []FieldGroupCorrelation{{FieldGroups: defaultFieldGroups, Templates: r.GetTemplates()}}
+}
+
 func (c *ComponentV1) getMissingCRs(matchedTemplates map[string]int) ValidationIssue {
 	var crs []string
 	metadata := make(map[string]CRMetadata)
@@ -207,6 +223,26 @@ func (config ReferenceTemplateConfigV1) GetInlineDiffFuncs() map[string]inlineDi
 	return map[string]inlineDiffType{}
 }
 
+func (config ReferenceTemplateConfigV1) GetListMergeKeys() map[string]string {
+	return map[string]string{}
+}
+
+func (config ReferenceTemplateConfigV1) GetListTypes() map[string]listType {
+	return map[string]listType{}
+}
+
+func (config ReferenceTemplateConfigV1) GetEmbeddedDocuments() map[string]documentFormat {
+	return map[string]documentFormat{}
+}
+
+func (config ReferenceTemplateConfigV1) GetSingleInstance() bool {
+	return false
+}
+
+func (config ReferenceTemplateConfigV1) GetOwnerTemplate() string {
+	return ""
+}
+
 func (config ReferenceTemplateConfigV1) GetFieldsToOmitRefs() []string {
 	return config.FieldsToOmitRefs
 }
@@ -253,9 +289,18 @@ func (rf ReferenceTemplateV1) ValidateFieldsToOmit(fieldsToOmit FieldsToOmit) er
 const noValue = "<no value>"
 
 func (rf ReferenceTemplateV1) Exec(params map[string]any) (*unstructured.Unstructured, error) {
+	// CRs are diffed concurrently, each execution counts how deep its own includes are in a copy of the template
+	t, err := rf.Template.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy template %s: %w", rf.GetIdentifier(), err)
+	}
+	bindIncludeFuncs(t)
 	var buf bytes.Buffer
-	err := rf.Template.Execute(&buf, params)
+	err = t.Execute(&buf, params)
 	if err != nil {
+		if missingKeyErr := asMissingKeyError(rf.Path, err); missingKeyErr != nil {
+			return nil, missingKeyErr
+		}
 		return nil, fmt.Errorf("failed to constuct template: %w", err)
 	}
 	data := make(map[string]any)
@@ -270,6 +315,13 @@ func (rf ReferenceTemplateV1) Exec(params map[string]any) (*unstructured.Unstruc
 	return &unstructured.Unstructured{Object: data}, nil
 }
 
+// bindFuncs replaces the late-bound functions of the template
+func (rf ReferenceTemplateV1) bindFuncs(funcs template.FuncMap) {
+	if rf.Template != nil {
+		rf.Template.Funcs(funcs)
+	}
+}
+
 func (rf ReferenceTemplateV1) GetPath() string {
 	return rf.Path
 }
@@ -291,9 +343,24 @@ func (rf ReferenceTemplateV1) GetConfig() TemplateConfig {
 }
 
 func (rf ReferenceTemplateV1) GetTemplateTree() *parse.Tree {
+	if rf.Template == nil {
+		return nil
+	}
 	return rf.Tree
 }
 
+// lookupTemplateTree returns the tree of a named template the template can invoke, nil when it can't invoke any
+// template with the name
+func (rf ReferenceTemplateV1) lookupTemplateTree(name string) *parse.Tree {
+	if rf.Template == nil {
+		return nil
+	}
+	if named := rf.Template.Lookup(name); named != nil {
+		return named.Tree
+	}
+	return nil
+}
+
 const builtInPathsKey = "cluster-compare-built-in"
 
 var builtInPathsV1 = []*ManifestPathV1{
@@ -311,30 +378,35 @@ var builtInPathsV1 = []*ManifestPathV1{
 type ManifestPathV1 struct {
 	PathToKey string `json:"pathToKey"`
 	IsPrefix  bool   `json:"isPrefix,omitempty"`
-	parts     []string
+	path      keyPath
 }
 
 func (p *ManifestPathV1) Process() error {
-	if len(p.parts) > 0 {
+	if len(p.path) > 0 {
 		return nil
 	}
-	var err error
-	p.parts, err = pathToList(p.PathToKey)
-	return err
-}
-
-func pathToList(path string) ([]string, error) {
-	pathToKey, _ := strings.CutPrefix(path, ".")
-	r := csv.NewReader(strings.NewReader(pathToKey))
-	r.Comma = '.'
-	fields, err := r.Read()
+	path, err := parsePathToKey(p.PathToKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse path: %w", err)
+		return err
+	}
+	if p.IsPrefix {
+		last := &path[len(path)-1]
+		switch last.kind {
+		case keySegment:
+			last.kind, last.glob = globSegment, regexp.MustCompile("^"+regexp.QuoteMeta(last.key))
+		case globSegment:
+			if last.glob, err = globToRegexp(last.key + "*"); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("pathToKey %s with isPrefix must end with a key", p.PathToKey)
+		}
 	}
-	return fields, nil
+	p.path = path
+	return nil
 }
 
-func ParseV1Templates(ref *ReferenceV1, fsys fs.FS) ([]ReferenceTemplate, error) {
+func ParseV1Templates(ref *ReferenceV1, fsys fs.FS, values map[string]any) ([]ReferenceTemplate, error) {
 	var errs []error
 	var result []ReferenceTemplate
 	functionTemplates := ref.TemplateFunctionFiles
@@ -352,8 +424,9 @@ func ParseV1Templates(ref *ReferenceV1, fsys fs.FS) ([]ReferenceTemplate, error)
 				continue
 			}
 		}
+		bindIncludeFuncs(parsedTemp)
 		temp.Template = parsedTemp
-		temp.metadata, err = temp.Exec(map[string]any{}) // Extract Metadata
+		temp.metadata, err = temp.Exec(templateData(map[string]any{}, values)) // Extract Metadata
 		if err != nil {
 			errs = append(errs, fmt.Errorf("failed to parse template %s with empty data: %w", temp.Path, err))
 		}
@@ -9,13 +9,12 @@ import (
 	"io/fs"
 	"path"
 	"reflect"
+	"regexp"
 	"slices"
 	"strings"
 	"text/template"
 
 	"k8s.io/klog/v2"
-
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 const ReferenceVersionV2 string = "v2"
@@ -27,6 +26,89 @@ type ReferenceV2 struct {
 	Parts                 []*PartV2       `json:"parts"`
 	TemplateFunctionFiles []string        `json:"templateFunctionFiles,omitempty"`
 	FieldsToOmit          *FieldsToOmitV2 `json:"fieldsToOmit,omitempty"`
+	// GlobalCaptureGroups are capture groups that must match the same value in every CR, not only within each CR
+	GlobalCaptureGroups []string `json:"globalCaptureGroups,omitempty"`
+	// Correlation declares groups of fields the templates of every part are correlated by
+	Correlation *CorrelationV2 `json:"correlation,omitempty"`
+	// Values are the default values of the parameters of the reference, templates get them under .Values
+	Values map[string]any `json:"values,omitempty"`
+	// ValuesSchema is a JSON schema the values are validated against before the comparison starts
+	ValuesSchema map[string]any `json:"valuesSchema,omitempty"`
+	// StrictMissingKeys makes templates fail on fields missing from their data instead of rendering them empty, it's
+	// a pointer so an overlay can unset it
+	StrictMissingKeys *bool `json:"strictMissingKeys,omitempty"`
+	// Extends is the path or URL of the metadata.yaml of a base reference this reference is an overlay of
+	Extends string `json:"extends,omitempty"`
+	// extendedFS are the directories of the references this reference extends, nearest first
+	extendedFS []fs.FS
+	// functionFileLayers is the layer of each templateFunctionFile declared by an extended reference, see fileLayerFS
+	functionFileLayers map[string]int
+}
+
+// CorrelationV2 declares groups of fields, in the pathToKey syntax, that cluster CRs are correlated to templates by.
+// The groups are tried before the default field groups unless ReplaceDefaultFieldGroups is set.
+type CorrelationV2 struct {
+	FieldGroups               [][]string `json:"fieldGroups,omitempty"`
+	ReplaceDefaultFieldGroups bool       `json:"replaceDefaultFieldGroups,omitempty"`
+}
+
+func (c *CorrelationV2) validate() error {
+	if c == nil {
+		return nil
+	}
+	errs := make([]error, 0)
+	for i, group := range c.FieldGroups {
+		if len(group) == 0 {
+			errs = append(errs, fmt.Errorf("correlation field group %d is empty", i))
+		}
+		for _, field := range group {
+			if _, err := parseFieldGroupField(field); err != nil {
+				errs = append(errs, fmt.Errorf("correlation field group %d: %w", i, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// hasFieldGroups reports whether c declares field groups, a nil config declares none
+func (c *CorrelationV2) hasFieldGroups() bool {
+	return c != nil && len(c.FieldGroups) > 0
+}
+
+func (c *CorrelationV2) replacesDefaults() bool {
+	return c != nil && c.ReplaceDefaultFieldGroups
+}
+
+// fieldGroups returns the declared groups in the format used by the GroupCorrelator
+func (c *CorrelationV2) fieldGroups() [][][]string {
+	result := make([][][]string, 0, len(c.FieldGroups))
+	for _, group := range c.FieldGroups {
+		fields := make([][]string, 0, len(group))
+		for _, field := range group {
+			// The fields are validated when the reference is parsed
+			path, _ := parseFieldGroupField(field)
+			fields = append(fields, path)
+		}
+		result = append(result, fields)
+	}
+	return result
+}
+
+// parseFieldGroupField parses a field of a correlation field group, the field has to point to a single value
+// within nested maps so it can only be made of plain keys.
+func parseFieldGroupField(field string) ([]string, error) {
+	path, err := parsePathToKey(field)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(path))
+	for _, segment := range path {
+		if segment.kind != keySegment {
+			return nil, fmt.Errorf("field %q contains a glob or a list index, only keys are supported", field)
+		}
+		result = append(result, segment.key)
+	}
+	return result, nil
 }
 
 func (r *ReferenceV2) GetAPIVersion() string {
@@ -60,9 +142,68 @@ func (r *ReferenceV2) GetTemplateFunctionFiles() []string {
 	return r.TemplateFunctionFiles
 }
 
+func (r *ReferenceV2) GetGlobalCaptureGroups() []string {
+	return r.GlobalCaptureGroups
+}
+
+func (r *ReferenceV2) GetValues() map[string]any {
+	return r.Values
+}
+
+func (r *ReferenceV2) GetValuesSchema() map[string]any {
+	return r.ValuesSchema
+}
+
+// GetFieldGroupCorrelations returns the field groups of the parts, then the field groups of the reference and then
+// the default field groups. A part that replaces the default field groups only has its templates correlated by its own groups.
+func (r *ReferenceV2) GetFieldGroupCorrelations() []FieldGroupCorrelation {
+	result := make([]FieldGroupCorrelation, 0)
+	remaining := make([]ReferenceTemplate, 0)
+	for _, part := range r.Parts {
+		templates := make([]ReferenceTemplate, 0)
+		for _, comp := range part.Components {
+			for _, t := range comp.getTemplates(part) {
+				templates = append(templates, t)
+			}
+		}
+		if part.Correlation.hasFieldGroups() {
+			result = append(result, FieldGroupCorrelation{FieldGroups: part.Correlation.fieldGroups(), Templates: templates, custom: true})
+		}
+		if !part.Correlation.replacesDefaults() {
+			remaining = append(remaining, templates...)
+		}
+	}
+	if r.Correlation.hasFieldGroups() {
+		result = append(result, FieldGroupCorrelation{FieldGroups: r.Correlation.fieldGroups(), Templates: remaining, custom: true})
+	}
+	if !r.Correlation.replacesDefaults() {
+		result = append(result, FieldGroupCorrelation{FieldGroups: defaultFieldGroups, Templates: remaining})
+	}
+	return result
+}
+
+var captureGroupName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 func (r *ReferenceV2) validate() error {
 	errs := make([]error, 0)
+	for _, name := range r.GlobalCaptureGroups {
+		if !captureGroupName.MatchString(name) {
+			errs = append(errs, fmt.Errorf("globalCaptureGroups contains invalid capture group name %q", name))
+		}
+	}
+	if err := r.Correlation.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("reference correlation is invalid: %w", err))
+	}
+	if r.ValuesSchema != nil {
+		if _, err := compileValuesSchema(r.ValuesSchema); err != nil {
+			errs = append(errs, err)
+		}
+	}
 	for _, part := range r.Parts {
+		errs = append(errs, part.validateOverlayActions()...)
+		if err := part.Correlation.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("correlation of part %s is invalid: %w", part.Name, err))
+		}
 		for i, comp := range part.Components {
 			err := comp.validate(i)
 			if err != nil {
@@ -220,7 +361,11 @@ type ReferenceTemplateV2 struct {
 	Config    ReferenceTemplateConfigV2 `json:"config,omitempty"`
 	part      *PartV2                   `json:"-"`
 	component *ComponentV2              `json:"-"`
+	// layer is the reference the template file is read from: 0 for the reference itself, then the references it
+	// extends nearest first
+	layer int
 	ReferenceTemplateV1
+	OverlayActions
 }
 
 func (rf ReferenceTemplateV2) GetConfig() TemplateConfig {
@@ -241,37 +386,132 @@ func (rf ReferenceTemplateV2) GetDescription() string {
 
 type ReferenceTemplateConfigV2 struct {
 	PerField []*PerFieldConfigV2 `json:"perField,omitempty"`
+	// SingleInstance templates are matched to one CR at most, the CRs they are candidates for are assigned
+	// to templates together once all CRs are diffed
+	SingleInstance bool `json:"singleInstance,omitempty"`
+	// OwnerTemplate is the template of the CR expected in the ownerReferences of the CRs matched to this template
+	OwnerTemplate string `json:"ownerTemplate,omitempty"`
+	// StrictMissingKeys overrides the strictMissingKeys of the reference for the template
+	StrictMissingKeys *bool `json:"strictMissingKeys,omitempty"`
 	ReferenceTemplateConfigV1
 }
 
+func (config ReferenceTemplateConfigV2) strictMissingKeys(referenceDefault bool) bool {
+	if config.StrictMissingKeys != nil {
+		return *config.StrictMissingKeys
+	}
+	return referenceDefault
+}
+
+func (config ReferenceTemplateConfigV2) GetSingleInstance() bool {
+	return config.SingleInstance
+}
+
+func (config ReferenceTemplateConfigV2) GetOwnerTemplate() string {
+	return config.OwnerTemplate
+}
+
 func (config ReferenceTemplateConfigV2) GetInlineDiffFuncs() map[string]inlineDiffType {
 	diffFuncs := make(map[string]inlineDiffType)
 	for _, fieldConf := range config.PerField {
-		diffFuncs[fieldConf.PathToKey] = fieldConf.InlineDiffFunc
+		if fieldConf.InlineDiffFunc != "" {
+			diffFuncs[fieldConf.PathToKey] = fieldConf.InlineDiffFunc
+		}
 	}
 	return diffFuncs
 }
 
+func (config ReferenceTemplateConfigV2) GetListMergeKeys() map[string]string {
+	mergeKeys := make(map[string]string)
+	for _, fieldConf := range config.PerField {
+		if fieldConf.MergeKey != "" {
+			mergeKeys[fieldConf.PathToKey] = fieldConf.MergeKey
+		}
+	}
+	return mergeKeys
+}
+
+func (config ReferenceTemplateConfigV2) GetEmbeddedDocuments() map[string]documentFormat {
+	formats := make(map[string]documentFormat)
+	for _, fieldConf := range config.PerField {
+		if fieldConf.EmbeddedDocument != "" {
+			formats[fieldConf.PathToKey] = fieldConf.EmbeddedDocument
+		}
+	}
+	return formats
+}
+
+func (config ReferenceTemplateConfigV2) GetListTypes() map[string]listType {
+	types := make(map[string]listType)
+	for _, fieldConf := range config.PerField {
+		if fieldConf.ListType != "" {
+			types[fieldConf.PathToKey] = fieldConf.ListType
+		}
+	}
+	return types
+}
+
+const perFieldPathNotFound = "reference contains template with config per field with pathToKey that points to a " +
+	"path that does not exist in the template. path: %s"
+
 func (rf ReferenceTemplateV2) validateConfigPerField() error {
+	for _, fieldConf := range rf.Config.PerField {
+		if fieldConf.InlineDiffFunc == "" && fieldConf.MergeKey == "" && fieldConf.ListType == "" && fieldConf.EmbeddedDocument == "" {
+			return fmt.Errorf("reference contains template with config per field that sets none of inlineDiffFunc, "+
+				"mergeKey, listType or embeddedDocument. path: %s", fieldConf.PathToKey)
+		}
+		if fieldConf.EmbeddedDocument != "" && (fieldConf.InlineDiffFunc != "" || fieldConf.MergeKey != "" || fieldConf.ListType != "") {
+			return fmt.Errorf("reference contains template with config per field that sets embeddedDocument with "+
+				"another option, options for the content of the document use paths within it. path: %s", fieldConf.PathToKey)
+		}
+		if fieldConf.EmbeddedDocument != "" && !slices.Contains(documentFormats, fieldConf.EmbeddedDocument) {
+			return fmt.Errorf("reference contains template with config per field with embeddedDocument format that "+
+				"does not exist. embeddedDocument: %s. supported: %v", fieldConf.EmbeddedDocument, documentFormats)
+		}
+		if fieldConf.MergeKey != "" && fieldConf.ListType != "" {
+			return fmt.Errorf("reference contains template with config per field that sets both mergeKey and "+
+				"listType. path: %s", fieldConf.PathToKey)
+		}
+		if fieldConf.ListType != "" && !slices.Contains(listTypes, fieldConf.ListType) {
+			return fmt.Errorf("reference contains template with config per field with listType that does not "+
+				"exist. listType: %s. supported: %v", fieldConf.ListType, listTypes)
+		}
+		if fieldConf.MergeKey == "" && fieldConf.ListType == "" && fieldConf.EmbeddedDocument == "" {
+			continue
+		}
+		if _, err := parsePathToKey(fieldConf.PathToKey); err != nil {
+			return fmt.Errorf("reference contains template with config per field with pathToKey that is not in "+
+				"supoorted format. path: %s. error: %v", fieldConf.PathToKey, err)
+		}
+	}
+	// The template failing to execute with empty data is reported by itself
+	if rf.metadata == nil {
+		return nil
+	}
+	// Paths of inline diff funcs may point within embedded documents
+	template := rf.metadata.DeepCopy().Object
+	decodeEmbeddedDocuments(template, newPathMatcher(rf.GetConfig().GetEmbeddedDocuments()))
 	for pathToKey, inlineDiffFunc := range rf.GetConfig().GetInlineDiffFuncs() {
-		listedPath, err := pathToList(pathToKey)
+		pattern, err := parsePathToKey(pathToKey)
 		if err != nil {
 			return fmt.Errorf("reference contains template with config per field with pathToKey that is not in "+
 				"supoorted format. path: %s. error: %v", pathToKey, err)
 		}
-		value, exist, err := unstructured.NestedString(rf.metadata.Object, listedPath...)
-		if err != nil || !exist {
-			return fmt.Errorf("reference contains template with config per field with pathToKey that points to a "+
-				"path that does not exist in the template. path: %s", pathToKey)
+		fieldPaths := pattern.expand(template)
+		if len(fieldPaths) == 0 && !pattern.hasWildcards() {
+			return fmt.Errorf(perFieldPathNotFound, pathToKey)
 		}
 		diffFn, ok := InlineDiffs[inlineDiffFunc]
 		if !ok {
 			return fmt.Errorf("reference contains template with config per field with InlineDiffFunc that does not "+
 				"exist. InlineDiffFunc: %s", inlineDiffFunc)
 		}
-		if err := diffFn.Validate(value); err != nil {
-			return fmt.Errorf("reference contains template with config per field with InlineDiffFunc that fails "+
-				"validation. InlineDiffFunc: %s. error: %v", inlineDiffFunc, err)
+		for _, fieldPath := range fieldPaths {
+			value, _ := fieldPath.get(template)
+			if err := diffFn.Validate(value); err != nil {
+				return fmt.Errorf("reference contains template with config per field with InlineDiffFunc that fails "+
+					"validation. InlineDiffFunc: %s. error: %v", inlineDiffFunc, err)
+			}
 		}
 	}
 	return nil
@@ -280,24 +520,46 @@ func (rf ReferenceTemplateV2) validateConfigPerField() error {
 type PerFieldConfigV2 struct {
 	PathToKey      string         `json:"pathToKey,omitempty"`
 	InlineDiffFunc inlineDiffType `json:"inlineDiffFunc,omitempty"`
+	MergeKey       string         `json:"mergeKey,omitempty"`
+	ListType       listType       `json:"listType,omitempty"`
+	// EmbeddedDocument is the format of the document held in the text of a string field
+	EmbeddedDocument documentFormat `json:"embeddedDocument,omitempty"`
 }
 
 type inlineDiffType string
 
 var InlineDiffs = map[inlineDiffType]InlineDiff{
-	regex:         RegexInlineDiff{},
-	capturegroups: CapturegroupsInlineDiff{},
-}
-
+	regex:                stringValues{RegexInlineDiff{}},
+	capturegroups:        stringValues{CapturegroupsInlineDiff{}},
+	quantity:             stringValues{QuantityInlineDiff{}},
+	numericRange:         stringValues{NumericRangeInlineDiff{}},
+	semverConstraint:     stringValues{SemverConstraintInlineDiff{}},
+	cidrContains:         stringValues{CIDRContainsInlineDiff{}},
+	ipInCIDR:             stringValues{IPInCIDRInlineDiff{}},
+	regexList:            listValues{stringValues{RegexInlineDiff{}}},
+	capturegroupsList:    listValues{stringValues{CapturegroupsInlineDiff{}}},
+	quantityList:         listValues{stringValues{QuantityInlineDiff{}}},
+	numericRangeList:     listValues{stringValues{NumericRangeInlineDiff{}}},
+	semverConstraintList: listValues{stringValues{SemverConstraintInlineDiff{}}},
+	cidrContainsList:     listValues{stringValues{CIDRContainsInlineDiff{}}},
+	ipInCIDRList:         listValues{stringValues{IPInCIDRInlineDiff{}}},
+	subsetOf:             SubsetOfInlineDiff{},
+}
+
+// InlineDiff is an inline diff function, it receives the values of the field in the template and in the
+// cluster CR, which may be any JSON value, and returns the value the template should have for the diff.
 type InlineDiff interface {
-	Diff(templateValue, crValue string) string
-	Validate(templateValue string) error
+	Diff(templateValue, crValue any) any
+	Validate(templateValue any) error
 }
 
 type PartV2 struct {
 	Name        string         `json:"name"`
 	Description string         `json:"description,omitempty"`
 	Components  []*ComponentV2 `json:"components"`
+	// Correlation declares groups of fields the templates of the part are correlated by
+	Correlation *CorrelationV2 `json:"correlation,omitempty"`
+	OverlayActions
 }
 
 func (p *PartV2) getValidationIssues(matchedTemplates map[string]int) (map[string]ValidationIssue, int) {
@@ -322,7 +584,8 @@ type ComponentV2 struct {
 	AnyOf       `json:"anyOf,omitempty"`
 	AnyOneOf    `json:"anyOneOf,omitempty"`
 	AllOrNoneOf `json:"allOrNoneOf,omitempty"`
-	parts       []ComponentV2Group
+	OverlayActions
+	parts []ComponentV2Group
 }
 
 type ComponentV2Group interface {
@@ -330,6 +593,7 @@ type ComponentV2Group interface {
 	GetTemplates(*PartV2, *ComponentV2) []*ReferenceTemplateV2
 	UnmarshalJSON([]byte) (err error)
 	getMissingCRs(map[string]int) (ValidationIssue, int)
+	templateList() []*ReferenceTemplateV2
 }
 
 type componentGroup struct {
@@ -340,6 +604,11 @@ func (g *componentGroup) SetTemplates(t []*ReferenceTemplateV2) {
 	g.templates = t
 }
 
+// templateList returns the templates of the group without setting their part and component
+func (g *componentGroup) templateList() []*ReferenceTemplateV2 {
+	return g.templates
+}
+
 func (g *componentGroup) GetTemplates(part *PartV2, component *ComponentV2) []*ReferenceTemplateV2 {
 	for _, t := range g.templates {
 		t.component = component
@@ -520,24 +789,16 @@ func (g *AllOrNoneOf) getMissingCRs(matchedTemplates map[string]int) (Validation
 	return ValidationIssue{}, 0
 }
 
+// groups returns every group of the component, including the ones without templates
+func (comp *ComponentV2) groups() []ComponentV2Group {
+	return []ComponentV2Group{&comp.OneOf, &comp.NoneOf, &comp.AllOf, &comp.AnyOf, &comp.AnyOneOf, &comp.AllOrNoneOf}
+}
+
 func (comp *ComponentV2) validate(index int) error {
-	if len(comp.OneOf.templates) > 0 {
-		comp.parts = append(comp.parts, &comp.OneOf)
-	}
-	if len(comp.NoneOf.templates) > 0 {
-		comp.parts = append(comp.parts, &comp.NoneOf)
-	}
-	if len(comp.AllOf.templates) > 0 {
-		comp.parts = append(comp.parts, &comp.AllOf)
-	}
-	if len(comp.AnyOf.templates) > 0 {
-		comp.parts = append(comp.parts, &comp.AnyOf)
-	}
-	if len(comp.AnyOneOf.templates) > 0 {
-		comp.parts = append(comp.parts, &comp.AnyOneOf)
-	}
-	if len(comp.AllOrNoneOf.templates) > 0 {
-		comp.parts = append(comp.parts, &comp.AllOrNoneOf)
+	for _, g := range comp.groups() {
+		if len(g.templateList()) > 0 {
+			comp.parts = append(comp.parts, g)
+		}
 	}
 
 	if len(comp.parts) == 0 {
@@ -569,8 +830,7 @@ func (comp ComponentV2) getValidationIssues(matchedTemplates map[string]int) (Va
 }
 
 func getReferenceV2(fsys fs.FS, referenceFileName string) (*ReferenceV2, error) {
-	result := &ReferenceV2{}
-	err := parseYaml(fsys, referenceFileName, &result, refConfNotExistsError, refConfigNotInFormat)
+	result, err := readReferenceV2(fsys, referenceFileName, []string{referenceLocation(fsys, referenceFileName)})
 	if err != nil {
 		return result, err
 	}
@@ -590,30 +850,36 @@ func getReferenceV2(fsys fs.FS, referenceFileName string) (*ReferenceV2, error)
 	return result, nil
 }
 
-func ParseV2Templates(ref *ReferenceV2, fsys fs.FS) ([]ReferenceTemplate, error) {
+func ParseV2Templates(ref *ReferenceV2, fsys fs.FS, values map[string]any) ([]ReferenceTemplate, error) {
 	var errs []error
 	var result []ReferenceTemplate
+	functionsFS := ReferenceFS(ref, fsys)
 	functionTemplates := ref.TemplateFunctionFiles
 	for _, temp := range ref.getTemplates() {
 		result = append(result, temp)
-		parsedTemp, err := template.New(path.Base(temp.Path)).Funcs(FuncMap()).ParseFS(fsys, temp.Path)
+		parsedTemp, err := template.New(path.Base(temp.Path)).Funcs(FuncMap()).ParseFS(ref.templateFS(fsys, temp), temp.Path)
 		if err != nil {
 			errs = append(errs, fmt.Errorf(templatesCantBeParsed, temp.Path, err))
 			continue
 		}
 		if len(functionTemplates) > 0 {
-			parsedTemp, err = parsedTemp.ParseFS(fsys, functionTemplates...)
+			parsedTemp, err = parsedTemp.ParseFS(functionsFS, functionTemplates...)
 			if err != nil {
 				errs = append(errs, fmt.Errorf(templatesFunctionsCantBeParsed, err))
 				continue
 			}
 		}
+		bindIncludeFuncs(parsedTemp)
 		temp.Template = parsedTemp
 		temp.ReferenceTemplateV1.Config = temp.Config.ReferenceTemplateConfigV1
-		temp.metadata, err = temp.Exec(map[string]any{}) // Extract Metadata
+		temp.metadata, err = temp.Exec(templateData(map[string]any{}, values)) // Extract Metadata
 		if err != nil {
 			errs = append(errs, fmt.Errorf("failed to parse template %s with empty data: %w", temp.Path, err))
 		}
+		// The metadata is extracted with no CR so it can only be extracted with missing keys allowed
+		if temp.Config.strictMissingKeys(ref.StrictMissingKeys != nil && *ref.StrictMissingKeys) {
+			setMissingKeyOption(parsedTemp, "error")
+		}
 		err = temp.validateConfigPerField()
 		if err != nil {
 			errs = append(errs, err)
@@ -626,5 +892,66 @@ func ParseV2Templates(ref *ReferenceV2, fsys fs.FS) ([]ReferenceTemplate, error)
 			errs = append(errs, fmt.Errorf("template missing kind: %s", temp.Path))
 		}
 	}
+	errs = append(errs, validateFieldGroupValues(ref)...)
+	errs = append(errs, validateOwnerTemplates(result)...)
 	return result, errors.Join(errs...) // nolint:wrapcheck
 }
+
+// validateOwnerTemplates checks that the owner templates are in the reference and that no template owns itself,
+// directly or through other templates.
+func validateOwnerTemplates(templates []ReferenceTemplate) []error {
+	var errs []error
+	ownerOf := make(map[string]string)
+	for _, temp := range templates {
+		ownerOf[temp.GetIdentifier()] = ""
+	}
+	for _, temp := range templates {
+		owner := temp.GetConfig().GetOwnerTemplate()
+		if owner == "" {
+			continue
+		}
+		if _, ok := ownerOf[owner]; !ok {
+			errs = append(errs, fmt.Errorf("template %s has ownerTemplate %s that isn't in the reference", temp.GetIdentifier(), owner))
+			continue
+		}
+		ownerOf[temp.GetIdentifier()] = owner
+	}
+	for _, temp := range templates {
+		seen := map[string]bool{temp.GetIdentifier(): true}
+		for owner := ownerOf[temp.GetIdentifier()]; owner != ""; owner = ownerOf[owner] {
+			if seen[owner] {
+				errs = append(errs, fmt.Errorf("template %s is its own owner through its ownerTemplate", temp.GetIdentifier()))
+				break
+			}
+			seen[owner] = true
+		}
+	}
+	return errs
+}
+
+// validateFieldGroupValues checks that the fields of the declared correlation field groups are scalars or lists of
+// scalars in the templates that set them, as the correlator can't group by other values. Templates that template any
+// of the fields aren't correlated by the group, so they aren't checked.
+func validateFieldGroupValues(ref *ReferenceV2) []error {
+	var errs []error
+	for _, correlation := range ref.GetFieldGroupCorrelations() {
+		if !correlation.custom {
+			continue
+		}
+		for _, temp := range correlation.Templates {
+			metadata := temp.GetMetadata()
+			if metadata == nil {
+				continue
+			}
+			for _, group := range correlation.FieldGroups {
+				for _, field := range group {
+					if _, _, err := groupFieldValue(metadata, field); err != nil {
+						errs = append(errs, fmt.Errorf("correlation field group contains field %s that isn't a scalar or a list of scalars in template %s",
+							strings.Join(field, "."), temp.GetPath()))
+					}
+				}
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,39 @@
+package compare
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+const (
+	semverConstraint inlineDiffType = "semverConstraint"
+)
+
+// SemverConstraintInlineDiff accepts any version that satisfies the constraint in the template, for example >=4.16 <5.
+// Versions may leave out the minor or patch number and may start with a v.
+type SemverConstraintInlineDiff struct{}
+
+func (id SemverConstraintInlineDiff) Diff(templateValue, crValue string) string {
+	constraint, err := semver.NewConstraint(templateValue)
+	if err != nil {
+		return templateValue
+	}
+	version, err := semver.NewVersion(strings.TrimSpace(crValue))
+	if err != nil {
+		return templateValue
+	}
+	if constraint.Check(version) {
+		return crValue
+	}
+	return templateValue
+}
+
+func (id SemverConstraintInlineDiff) Validate(templateValue string) error {
+	_, err := semver.NewConstraint(templateValue)
+	if err != nil {
+		return fmt.Errorf("invalid constraint passed to inline semverConstraint diff function: %w", err)
+	}
+	return nil
+}
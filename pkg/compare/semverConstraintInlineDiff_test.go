@@ -0,0 +1,30 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemverConstraintInlineDiff(t *testing.T) {
+	tests := []struct {
+		template string
+		cr       string
+		expected string
+	}{
+		{">=4.16 <5", "4.16.3", "4.16.3"},
+		{">=4.16 <5", "v4.17", "v4.17"},
+		{">=4.16 <5", "4.15.20", ">=4.16 <5"},
+		{">=4.16 <5", "5.0.0", ">=4.16 <5"},
+		{"~4.16", "4.16.9", "4.16.9"},
+		{"4.16.x || 4.18.x", "4.17.1", "4.16.x || 4.18.x"},
+		{">=4.16", "latest", ">=4.16"},
+	}
+	for _, test := range tests {
+		t.Run(test.template+" "+test.cr, func(t *testing.T) {
+			assert.Equal(t, test.expected, SemverConstraintInlineDiff{}.Diff(test.template, test.cr))
+		})
+	}
+	assert.NoError(t, SemverConstraintInlineDiff{}.Validate(">=4.16, <5"))
+	assert.Error(t, SemverConstraintInlineDiff{}.Validate("at least 4.16"))
+}
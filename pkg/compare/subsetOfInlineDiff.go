@@ -0,0 +1,64 @@
+package compare
+
+import (
+	"fmt"
+)
+
+const (
+	subsetOf inlineDiffType = "subsetOf"
+)
+
+// SubsetOfInlineDiff accepts a cluster CR list that contains at least the items listed in the template,
+// or a map that contains at least the keys and values in the template.
+type SubsetOfInlineDiff struct{}
+
+func (id SubsetOfInlineDiff) Diff(templateValue, crValue any) any {
+	switch template := templateValue.(type) {
+	case []any:
+		crList, ok := crValue.([]any)
+		if !ok {
+			return templateValue
+		}
+		// Items are matched once so a duplicated item in the template must be duplicated in the cluster CR too
+		matched := make([]bool, len(crList))
+		result := append([]any{}, crList...)
+		for _, item := range template {
+			found := false
+			for i, crItem := range crList {
+				if !matched[i] && valuesEqual(item, crItem) {
+					matched[i], found = true, true
+					break
+				}
+			}
+			if !found {
+				// Adding the missing items to the cluster CR list shows just them in the diff
+				result = append(result, item)
+			}
+		}
+		return result
+	case map[string]any:
+		crMap, ok := crValue.(map[string]any)
+		if !ok {
+			return templateValue
+		}
+		result := make(map[string]any, len(crMap))
+		for k, v := range crMap {
+			result[k] = v
+		}
+		for k, v := range template {
+			if crItem, ok := crMap[k]; !ok || !valuesEqual(v, crItem) {
+				result[k] = v
+			}
+		}
+		return result
+	}
+	return templateValue
+}
+
+func (id SubsetOfInlineDiff) Validate(templateValue any) error {
+	switch templateValue.(type) {
+	case []any, map[string]any:
+		return nil
+	}
+	return fmt.Errorf("subsetOf inline diff function expects a list or a map but got %T", templateValue)
+}
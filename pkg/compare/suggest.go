@@ -0,0 +1,162 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// The weights of the parts of the similarity score of a template and a CR, they add up to 1
+const (
+	kindWeight       = 0.3
+	apiVersionWeight = 0.1
+	fixedFieldWeight = 0.35
+	structureWeight  = 0.25
+)
+
+// minSuggestionScore is the lowest score of a suggested template, templates of another kind rarely get to it
+const minSuggestionScore = 0.5
+
+// TemplateSuggestion is a template that resembles a CR that wasn't matched to any template, scored from 0 to 1
+type TemplateSuggestion struct {
+	Template string  `json:"Template"`
+	Score    float64 `json:"Score"`
+}
+
+// UnmatchedCRSuggestions lists the templates that most resemble an unmatched CR and the correlation pair that
+// matches the CR to the best of them when added to the diff config.
+type UnmatchedCRSuggestions struct {
+	CRName          string               `json:"CRName"`
+	Suggestions     []TemplateSuggestion `json:"Suggestions"`
+	CorrelationPair string               `json:"CorrelationPair"`
+}
+
+// suggestTemplates returns, for each CR, the n templates with the highest similarity score
+func suggestTemplates(crs []*unstructured.Unstructured, templates []ReferenceTemplate, n int) []UnmatchedCRSuggestions {
+	result := make([]UnmatchedCRSuggestions, 0)
+	if n <= 0 {
+		return result
+	}
+	seen := make(map[string]bool)
+	for _, cr := range crs {
+		name := apiKindNamespaceName(cr)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		suggestions := make([]TemplateSuggestion, 0, len(templates))
+		for _, temp := range templates {
+			if temp.GetMetadata() == nil {
+				continue
+			}
+			score := similarity(temp.GetMetadata(), cr)
+			if score >= minSuggestionScore {
+				suggestions = append(suggestions, TemplateSuggestion{Template: temp.GetIdentifier(), Score: score})
+			}
+		}
+		if len(suggestions) == 0 {
+			continue
+		}
+		sort.SliceStable(suggestions, func(i, j int) bool {
+			if suggestions[i].Score != suggestions[j].Score {
+				return suggestions[i].Score > suggestions[j].Score
+			}
+			return suggestions[i].Template < suggestions[j].Template
+		})
+		if len(suggestions) > n {
+			suggestions = suggestions[:n]
+		}
+		result = append(result, UnmatchedCRSuggestions{
+			CRName:          name,
+			Suggestions:     suggestions,
+			CorrelationPair: fmt.Sprintf("%s: %s", name, suggestions[0].Template),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CRName < result[j].CRName })
+	return result
+}
+
+// similarity scores how much a CR resembles a template rendered with no values. It's made of whether they share
+// the kind and apiVersion, the share of the fixed fields of the template that have the same value in the CR and
+// the share of the fields of both that exist in the other, with list indices ignored.
+func similarity(template, cr *unstructured.Unstructured) float64 {
+	score := 0.0
+	if template.GetKind() == cr.GetKind() {
+		score += kindWeight
+	}
+	if template.GetAPIVersion() == cr.GetAPIVersion() {
+		score += apiVersionWeight
+	}
+
+	templateFields := leafFields(keyPath{}, template.Object, make(map[string]leafField))
+	crFields := leafFields(keyPath{}, cr.Object, make(map[string]leafField))
+
+	fixed, sameValue := 0, 0
+	for path, field := range templateFields {
+		// Templated fields render empty when the template is executed with no values
+		if field.value == nil || field.value == "" {
+			continue
+		}
+		fixed++
+		if crField, ok := crFields[path]; ok && valuesEqual(field.value, crField.value) {
+			sameValue++
+		}
+	}
+	if fixed > 0 {
+		score += fixedFieldWeight * float64(sameValue) / float64(fixed)
+	}
+
+	templateStructure, crStructure := structure(templateFields), structure(crFields)
+	shared := 0
+	for path := range templateStructure {
+		if crStructure[path] {
+			shared++
+		}
+	}
+	if union := len(templateStructure) + len(crStructure) - shared; union > 0 {
+		score += structureWeight * float64(shared) / float64(union)
+	}
+	return math.Round(score*100) / 100
+}
+
+type leafField struct {
+	path  keyPath
+	value any
+}
+
+// leafFields collects the scalar fields within value by their path in the pathToKey syntax
+func leafFields(path keyPath, value any, fields map[string]leafField) map[string]leafField {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, item := range v {
+			leafFields(path.withKey(k), item, fields)
+		}
+	case []any:
+		for i, item := range v {
+			leafFields(path.withIndex(i), item, fields)
+		}
+	default:
+		fields[path.String()] = leafField{path: path, value: value}
+	}
+	return fields
+}
+
+// structure returns the paths of the fields with every list index replaced by [*]
+func structure(fields map[string]leafField) map[string]bool {
+	result := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		path := make(keyPath, len(field.path))
+		for i, segment := range field.path {
+			if segment.isListItem() {
+				segment = pathSegment{kind: anyIndexSegment}
+			}
+			path[i] = segment
+		}
+		result[path.String()] = true
+	}
+	return result
+}
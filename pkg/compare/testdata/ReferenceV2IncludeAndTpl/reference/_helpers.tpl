@@ -0,0 +1,8 @@
+{{- define "dashboard.labels" -}}
+app.kubernetes.io/name: dashboard
+app.kubernetes.io/part-of: {{ .metadata.namespace | default "kubernetes-dashboard" }}
+{{- end -}}
+
+{{- define "dashboard.loop" -}}
+{{ include "dashboard.loop" . }}
+{{- end -}}
@@ -0,0 +1,469 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+	"k8s.io/utils/exec"
+)
+
+var (
+	testReferenceLong = templates.LongDesc(`
+		Test a reference configuration against sets of fixture CRs with known outcomes.
+
+		The test suite file lists the test cases of the reference. Each test case has a directory of fixture CRs that
+		are compared to the reference like local CRs passed to the compare command with --all-resources, and the
+		outcome expected for them: the template each CR is matched to, whether it has no diff or the exact diff it
+		has, the CRs that aren't matched to any template and the validation issues of the reference.
+
+		The directories, values files, diff configs and diff files of the test cases are relative to the test suite
+		file. Expected diffs are compared from their first hunk on, so the file names and dates of the diff header
+		don't matter.
+
+		Exit status: 0 All test cases passed. 1 Test cases failed. >1 The reference or the test suite couldn't be read.
+	`)
+
+	testReferenceExample = templates.Examples(`
+		# Test a reference configuration:
+		kubectl cluster-compare test-reference -r ./reference/metadata.yaml -s ./tests/suite.yaml
+
+		# Test a reference configuration and report the results as JUnit XML:
+		kubectl cluster-compare test-reference -r ./reference/metadata.yaml -s ./tests/suite.yaml -o junit
+	`)
+)
+
+const (
+	JUnit                = "junit"
+	TestsFailedMsg       = "reference test cases failed"
+	noTestSuiteWasPassed = "\"Test suite file is required\""
+	testSuiteNotExists   = "Test suite file not found. error: %w"
+	testSuiteNotInFormat = "Test suite file isn't in correct format. error: %w"
+)
+
+var TestReferenceOutputFormats = []string{JUnit}
+
+// ReferenceTestSuite lists the test cases of a reference
+type ReferenceTestSuite struct {
+	Tests []ReferenceTestCase `json:"tests"`
+}
+
+type ReferenceTestCase struct {
+	Name string `json:"name"`
+	// CRs is the directory of the fixture CRs of the test case
+	CRs        string              `json:"crs"`
+	Values     string              `json:"values,omitempty"`
+	DiffConfig string              `json:"diffConfig,omitempty"`
+	Expect     ReferenceTestExpect `json:"expect"`
+}
+
+// ReferenceTestExpect is the outcome expected when the fixture CRs of a test case are compared to the reference.
+// CRs that aren't listed aren't checked, the unmatched CRs and the validation issues have to be exactly the expected ones.
+type ReferenceTestExpect struct {
+	CRs              []ExpectedCR                          `json:"crs,omitempty"`
+	UnmatchedCRs     []string                              `json:"unmatchedCRs,omitempty"`
+	ValidationIssues map[string]map[string]ValidationIssue `json:"validationIssues,omitempty"`
+}
+
+type ExpectedCR struct {
+	// Name is the apiVersion_kind_namespace_name of the CR
+	Name     string `json:"name"`
+	Template string `json:"template,omitempty"`
+	// NoDiff expects the CR to have no diff with its template
+	NoDiff bool `json:"noDiff,omitempty"`
+	// Diff is the file with the diff the CR is expected to have with its template
+	Diff string `json:"diff,omitempty"`
+}
+
+// ReferenceTestResult holds the expectations of a test case that weren't met, or the error that kept it from running
+type ReferenceTestResult struct {
+	Name     string
+	Failures []string
+	Err      error
+}
+
+type ReferenceTestResults struct {
+	Suite   string
+	Results []ReferenceTestResult
+}
+
+func (r ReferenceTestResults) counts() (failed, errored int) {
+	for _, result := range r.Results {
+		switch {
+		case result.Err != nil:
+			errored++
+		case len(result.Failures) > 0:
+			failed++
+		}
+	}
+	return failed, errored
+}
+
+func (r ReferenceTestResults) String() string {
+	var sb strings.Builder
+	for _, result := range r.Results {
+		switch {
+		case result.Err != nil:
+			sb.WriteString(fmt.Sprintf("ERROR: %s\n%s\n", result.Name, listItem(result.Err.Error())))
+		case len(result.Failures) > 0:
+			sb.WriteString(fmt.Sprintf("FAIL: %s\n", result.Name))
+			for _, failure := range result.Failures {
+				sb.WriteString(listItem(failure) + "\n")
+			}
+		default:
+			sb.WriteString(fmt.Sprintf("PASS: %s\n", result.Name))
+		}
+	}
+	failed, errored := r.counts()
+	sb.WriteString(fmt.Sprintf("%d test cases, %d passed, %d failed, %d errors\n",
+		len(r.Results), len(r.Results)-failed-errored, failed, errored))
+	return sb.String()
+}
+
+// listItem indents the text as an item of the list of failures of a test case
+func listItem(text string) string {
+	return "  - " + strings.TrimPrefix(indent(text, 4), "    ")
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func (r ReferenceTestResults) junit() junitTestSuites {
+	failed, errored := r.counts()
+	suite := junitTestSuite{Name: r.Suite, Tests: len(r.Results), Failures: failed, Errors: errored}
+	for _, result := range r.Results {
+		testCase := junitTestCase{Name: result.Name, ClassName: r.Suite}
+		switch {
+		case result.Err != nil:
+			testCase.Error = &junitMessage{Message: "test case couldn't run", Text: result.Err.Error()}
+		case len(result.Failures) > 0:
+			testCase.Failure = &junitMessage{
+				Message: fmt.Sprintf("%d expectations weren't met", len(result.Failures)),
+				Text:    strings.Join(result.Failures, "\n"),
+			}
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	return junitTestSuites{Tests: suite.Tests, Failures: failed, Errors: errored, Suites: []junitTestSuite{suite}}
+}
+
+func (r ReferenceTestResults) Print(format string, out io.Writer) (int, error) {
+	var content []byte
+	switch format {
+	case JUnit:
+		report, err := xml.MarshalIndent(r.junit(), "", "  ")
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal test results to junit: %w", err)
+		}
+		content = append([]byte(xml.Header), report...)
+		content = append(content, []byte("\n")...)
+	default:
+		content = []byte(r.String())
+	}
+	n, err := out.Write(content)
+	if err != nil {
+		return n, fmt.Errorf("error occurred when writing output: %w", err)
+	}
+	return n, nil
+}
+
+type TestReferenceOptions struct {
+	referenceConfig string
+	suiteFile       string
+	OutputFormat    string
+	Concurrency     int
+
+	suite ReferenceTestSuite
+	// references holds the reference loaded with each values file of the test cases
+	references map[string]*loadedReference
+	factory    kcmdutil.Factory
+	cmd        *cobra.Command
+	genericiooptions.IOStreams
+}
+
+func NewTestReferenceOptions(ioStreams genericiooptions.IOStreams) *TestReferenceOptions {
+	return &TestReferenceOptions{IOStreams: ioStreams}
+}
+
+func NewTestReferenceCmd(f kcmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	options := NewTestReferenceOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:                   "test-reference -r <Reference File> -s <Test Suite File>",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Test a reference configuration against fixture CRs with expected outcomes."),
+		Long:                  testReferenceLong,
+		Example:               commandExample(testReferenceExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckDiffErr(options.Complete(f, cmd, args))
+			// Like compare, failed test cases exit with 1 and failing to run the suite exits with more
+			if err := options.Run(); err != nil {
+				if exitErr := diffError(err); exitErr != nil {
+					kcmdutil.CheckErr(kcmdutil.ErrExit)
+				}
+				kcmdutil.CheckDiffErr(err)
+			}
+		},
+	}
+
+	cmd.SetFlagErrorFunc(func(command *cobra.Command, err error) error {
+		kcmdutil.CheckDiffErr(kcmdutil.UsageErrorf(cmd, err.Error()))
+		return nil
+	})
+	cmd.Flags().StringVarP(&options.referenceConfig, "reference", "r", "", "Path to reference config file.")
+	cmd.Flags().StringVarP(&options.suiteFile, "suite", "s", "", "Path to the test suite file")
+	cmd.Flags().IntVar(&options.Concurrency, "concurrency", 4,
+		"Number of fixture CRs of a test case to process in parallel. Larger number = faster,"+
+			" but more memory, I/O and CPU over that shorter period of time.")
+	cmd.Flags().StringVarP(&options.OutputFormat, "output", "o", "", fmt.Sprintf(`Output format. One of: (%s)`, strings.Join(TestReferenceOutputFormats, ", ")))
+	kcmdutil.CheckErr(cmd.RegisterFlagCompletionFunc(
+		"output",
+		func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			var comps []string
+			for _, format := range TestReferenceOutputFormats {
+				if strings.HasPrefix(format, toComplete) {
+					comps = append(comps, format)
+				}
+			}
+			return comps, cobra.ShellCompDirectiveNoFileComp
+		},
+	))
+
+	return cmd
+}
+
+func (o *TestReferenceOptions) Complete(f kcmdutil.Factory, cmd *cobra.Command, args []string) error {
+	o.factory = f
+	o.cmd = cmd
+	if len(args) != 0 {
+		return kcmdutil.UsageErrorf(cmd, "Unexpected args: %v", args)
+	}
+	if o.OutputFormat != "" && !slices.Contains(TestReferenceOutputFormats, o.OutputFormat) {
+		return kcmdutil.UsageErrorf(cmd, "Unsupported output format %s, supported: %s", o.OutputFormat, strings.Join(TestReferenceOutputFormats, ", "))
+	}
+	if o.referenceConfig == "" {
+		return kcmdutil.UsageErrorf(cmd, noRefFileWasPassed)
+	}
+	if o.suiteFile == "" {
+		return kcmdutil.UsageErrorf(cmd, noTestSuiteWasPassed)
+	}
+	suiteDir, err := filepath.Abs(filepath.Dir(o.suiteFile))
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	return parseYaml(os.DirFS(suiteDir), filepath.Base(o.suiteFile), &o.suite, testSuiteNotExists, testSuiteNotInFormat)
+}
+
+func (o *TestReferenceOptions) Run() error {
+	// A reference that can't be read fails the whole suite instead of each of its test cases
+	if _, err := o.loadReference(""); err != nil {
+		return err
+	}
+	results := ReferenceTestResults{Suite: filepath.Base(o.suiteFile)}
+	for _, testCase := range o.suite.Tests {
+		results.Results = append(results.Results, o.runTestCase(testCase))
+	}
+	if _, err := results.Print(o.OutputFormat, o.Out); err != nil {
+		return err
+	}
+	if failed, errored := results.counts(); failed+errored > 0 {
+		return exec.CodeExitError{Err: errors.New(TestsFailedMsg), Code: 1}
+	}
+	return nil
+}
+
+// loadReference returns the reference loaded with the values file, the reference is read and parsed once for each
+// values file and shared by the test cases that use it. The metadata of the templates depends on the values so test
+// cases with other values files can't share it.
+func (o *TestReferenceOptions) loadReference(valuesFile string) (*loadedReference, error) {
+	if ref, ok := o.references[valuesFile]; ok {
+		return ref, nil
+	}
+	ref, err := loadReference(o.referenceConfig, valuesFile)
+	if err != nil {
+		return nil, err
+	}
+	if o.references == nil {
+		o.references = make(map[string]*loadedReference)
+	}
+	o.references[valuesFile] = ref
+	return ref, nil
+}
+
+// suitePath returns the path of a file of the test suite, the paths in the suite are relative to its file
+func (o *TestReferenceOptions) suitePath(name string) string {
+	if name == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(filepath.Dir(o.suiteFile), name)
+}
+
+// runTestCase compares the fixture CRs of the test case to the reference like the compare command does for local
+// CRs and checks the outcome against the expectations of the test case
+func (o *TestReferenceOptions) runTestCase(testCase ReferenceTestCase) ReferenceTestResult {
+	result := ReferenceTestResult{Name: testCase.Name}
+	if testCase.CRs == "" {
+		result.Err = fmt.Errorf("test case has no crs directory")
+		return result
+	}
+	reference, err := o.loadReference(o.suitePath(testCase.Values))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	var out bytes.Buffer
+	compareOptions := NewOptions(genericiooptions.IOStreams{In: o.In, Out: &out, ErrOut: o.ErrOut})
+	compareOptions.referenceConfig = o.referenceConfig
+	compareOptions.reference = reference
+	compareOptions.CRs = resource.FilenameOptions{Filenames: []string{o.suitePath(testCase.CRs)}, Recursive: true}
+	compareOptions.valuesFile = o.suitePath(testCase.Values)
+	compareOptions.diffConfigFileName = o.suitePath(testCase.DiffConfig)
+	compareOptions.OutputFormat = Json
+	compareOptions.diffAll = true
+	compareOptions.Concurrency = o.Concurrency
+
+	if err := compareOptions.Complete(o.factory, o.cmd, nil); err != nil {
+		result.Err = err
+		return result
+	}
+	if err := compareOptions.Run(); err != nil && diffError(err) == nil {
+		result.Err = err
+		return result
+	}
+	var output Output
+	if err := json.Unmarshal(out.Bytes(), &output); err != nil {
+		result.Err = fmt.Errorf("failed to read the output of the comparison: %w", err)
+		return result
+	}
+	failures, err := o.checkExpectations(testCase.Expect, output)
+	result.Failures, result.Err = failures, err
+	return result
+}
+
+func (o *TestReferenceOptions) checkExpectations(expect ReferenceTestExpect, output Output) ([]string, error) {
+	var failures []string
+	diffs := make(map[string]DiffSum)
+	if output.Diffs != nil {
+		for _, diff := range *output.Diffs {
+			diffs[diff.CRName] = diff
+		}
+	}
+	for _, expected := range expect.CRs {
+		actual, ok := diffs[expected.Name]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("CR %s wasn't matched to any template", expected.Name))
+			continue
+		}
+		if expected.Template != "" && actual.CorrelatedTemplate != expected.Template {
+			failures = append(failures, fmt.Sprintf("CR %s was matched to template %s instead of %s",
+				expected.Name, actual.CorrelatedTemplate, expected.Template))
+		}
+		if expected.NoDiff && actual.HasDiff() {
+			failures = append(failures, fmt.Sprintf("CR %s was expected to have no diff but has:\n%s",
+				expected.Name, strings.TrimSuffix(diffHunks(actual.DiffOutput), "\n")))
+		}
+		if expected.Diff != "" {
+			content, err := os.ReadFile(o.suitePath(expected.Diff))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the expected diff of CR %s: %w", expected.Name, err)
+			}
+			switch {
+			case !actual.HasDiff():
+				failures = append(failures, fmt.Sprintf("CR %s was expected to have the diff in %s but has no diff",
+					expected.Name, expected.Diff))
+			case diffHunks(actual.DiffOutput) != diffHunks(string(content)):
+				failures = append(failures, fmt.Sprintf("CR %s was expected to have the diff in %s but has:\n%s",
+					expected.Name, expected.Diff, strings.TrimSuffix(diffHunks(actual.DiffOutput), "\n")))
+			}
+		}
+	}
+
+	var unmatched []string
+	if output.Summary != nil {
+		unmatched = slices.Clone(output.Summary.UnmatchedCRS)
+	}
+	expectedUnmatched := slices.Clone(expect.UnmatchedCRs)
+	sort.Strings(unmatched)
+	sort.Strings(expectedUnmatched)
+	if !slices.Equal(unmatched, expectedUnmatched) {
+		failures = append(failures, fmt.Sprintf("expected unmatched CRs [%s] but got [%s]",
+			strings.Join(expectedUnmatched, ", "), strings.Join(unmatched, ", ")))
+	}
+
+	var issues map[string]map[string]ValidationIssue
+	if output.Summary != nil {
+		issues = output.Summary.ValidationIssues
+	}
+	if expectedIssues, actualIssues := validationIssueLines(expect.ValidationIssues), validationIssueLines(issues); !slices.Equal(expectedIssues, actualIssues) {
+		failures = append(failures, fmt.Sprintf("expected validation issues:\n%s\nbut got:\n%s",
+			indent(strings.Join(expectedIssues, "\n"), 2), indent(strings.Join(actualIssues, "\n"), 2)))
+	}
+	return failures, nil
+}
+
+// diffHunks returns the diff from its first hunk on, without the header that names the compared files
+func diffHunks(diff string) string {
+	if i := strings.Index(diff, "@@"); i >= 0 {
+		return diff[i:]
+	}
+	return diff
+}
+
+// validationIssueLines returns a line for each validation issue, sorted, with the CRs of the issue sorted. The
+// metadata of the CRs is left out as it only describes them.
+func validationIssueLines(issues map[string]map[string]ValidationIssue) []string {
+	var lines []string
+	for part, components := range issues {
+		for component, issue := range components {
+			crs := slices.Clone(issue.CRs)
+			sort.Strings(crs)
+			lines = append(lines, fmt.Sprintf("%s/%s: %s: %s", part, component, issue.Msg, strings.Join(crs, ", ")))
+		}
+	}
+	sort.Strings(lines)
+	if len(lines) == 0 {
+		return []string{"None"}
+	}
+	return lines
+}
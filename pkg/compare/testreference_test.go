@@ -0,0 +1,60 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"path"
+	"testing"
+
+	"github.com/openshift/kube-compare/pkg/testutils"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+)
+
+func TestReferenceTestSuite(t *testing.T) {
+	testDir := path.Join(TestDirs, "ReferenceTestSuite")
+	tf := cmdtesting.NewTestFactory()
+	defer tf.Cleanup()
+	for _, outputFormat := range []string{"", JUnit} {
+		golden := defaultOutSuffix
+		if outputFormat != "" {
+			golden = outputFormat + "_" + golden
+		}
+		t.Run(golden, func(t *testing.T) {
+			streams, _, out, _ := genericiooptions.NewTestIOStreams()
+			cmd := NewTestReferenceCmd(tf, streams)
+			options := NewTestReferenceOptions(streams)
+			options.referenceConfig = path.Join(testDir, TestRefDirName, defaultReferenceFilename)
+			options.suiteFile = path.Join(testDir, "tests", "suite.yaml")
+			options.OutputFormat = outputFormat
+			require.NoError(t, options.Complete(tf, cmd, []string{}))
+
+			err := options.Run()
+			exitErr := diffError(err)
+			require.NotNil(t, exitErr, "test suite failed to run: %v", err)
+			require.Equal(t, 1, exitErr.ExitStatus())
+			require.Len(t, options.references, 1, "test cases without values files share the reference")
+			value := testutils.RemoveInconsistentInfo(t, out.String())
+			expected := testutils.GetFile(t, path.Join(testDir, golden), value, *update)
+			require.Equal(t, expected, value)
+		})
+	}
+}
+
+func TestReferenceTestSuiteWithInvalidReference(t *testing.T) {
+	testDir := path.Join(TestDirs, "ReferenceTestSuite")
+	tf := cmdtesting.NewTestFactory()
+	defer tf.Cleanup()
+	streams, _, out, _ := genericiooptions.NewTestIOStreams()
+	cmd := NewTestReferenceCmd(tf, streams)
+	options := NewTestReferenceOptions(streams)
+	options.referenceConfig = path.Join(testDir, TestRefDirName, "missing.yaml")
+	options.suiteFile = path.Join(testDir, "tests", "suite.yaml")
+	require.NoError(t, options.Complete(tf, cmd, []string{}))
+
+	err := options.Run()
+	require.Error(t, err)
+	require.Nil(t, diffError(err), "a reference that can't be read exits with more than 1")
+	require.Empty(t, out.String(), "no test case runs")
+}
@@ -0,0 +1,564 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// This file renders line based diffs in the same format as `diff -u -N` from GNU diffutils.
+// The line matching follows the same steps diffutils takes (discarding lines that can't match,
+// a linear space Myers search and sliding the change boundaries) so that the hunks produced are
+// the same ones users of the external diff command are used to.
+
+const (
+	// unifiedContext is the number of unchanged lines shown around each change, like `diff -u`.
+	unifiedContext = 3
+	// diffTimeFormat is the timestamp format used by GNU diff in the file headers.
+	diffTimeFormat = "2006-01-02 15:04:05.000000000 -0700"
+)
+
+// unifiedDiff returns the unified diff between from and to, labeling them with fromName and toName.
+// An empty string is returned when the contents are identical.
+func unifiedDiff(fromName, toName string, from, to []byte, modTime time.Time) string {
+	a := splitLines(from)
+	b := splitLines(to)
+	changedA, changedB := diffLines(a, b)
+	hunks := findHunks(changedA, changedB)
+	if len(hunks) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	timestamp := modTime.Format(diffTimeFormat)
+	fmt.Fprintf(&buf, "diff -u -N %s %s\n", fromName, toName)
+	fmt.Fprintf(&buf, "--- %s\t%s\n", fromName, timestamp)
+	fmt.Fprintf(&buf, "+++ %s\t%s\n", toName, timestamp)
+	for _, h := range hunks {
+		h.write(&buf, a, b)
+	}
+	return buf.String()
+}
+
+func splitLines(data []byte) []string {
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineFile holds the state of one side of a line diff. changed has a sentinel
+// entry on each side so the algorithms can look one line outside the region.
+type lineFile struct {
+	equivs      []int
+	changed     []bool
+	undiscarded []int
+	realIndexes []int
+}
+
+func newLineFile(equivs []int) *lineFile {
+	return &lineFile{equivs: equivs, changed: make([]bool, len(equivs)+2)}
+}
+
+func (f *lineFile) isChanged(i int) bool {
+	return f.changed[i+1]
+}
+
+func (f *lineFile) setChanged(i int, v bool) {
+	f.changed[i+1] = v
+}
+
+// diffLines marks which lines of a and b are not part of the longest common subsequence.
+func diffLines(a, b []string) (changedA, changedB []bool) {
+	changedA = make([]bool, len(a))
+	changedB = make([]bool, len(b))
+
+	// Lines shared at the start and end are only analysed if they are close enough to the changes to matter.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	if prefix == len(a) && prefix == len(b) {
+		return changedA, changedB
+	}
+	start := prefix - min(prefix, unifiedContext)
+	trimmedSuffix := suffix - min(suffix, unifiedContext)
+	regionA := a[start : len(a)-trimmedSuffix]
+	regionB := b[start : len(b)-trimmedSuffix]
+
+	classes := make(map[string]int)
+	equivsFor := func(lines []string) []int {
+		equivs := make([]int, len(lines))
+		for i, line := range lines {
+			class, ok := classes[line]
+			if !ok {
+				class = len(classes) + 1
+				classes[line] = class
+			}
+			equivs[i] = class
+		}
+		return equivs
+	}
+	files := [2]*lineFile{newLineFile(equivsFor(regionA)), newLineFile(equivsFor(regionB))}
+
+	discardConfusingLines(files, len(classes)+1)
+	s := lineSearch{x: files[0], y: files[1]}
+	s.compare(0, len(files[0].undiscarded), 0, len(files[1].undiscarded))
+	shiftBoundaries(files)
+
+	for i := range regionA {
+		changedA[start+i] = files[0].isChanged(i)
+	}
+	for i := range regionB {
+		changedB[start+i] = files[1].isChanged(i)
+	}
+	return changedA, changedB
+}
+
+// discardConfusingLines marks lines that have no match in the other file as changed and removes them,
+// along with runs of lines that match too many lines in the other file, from the lines that are searched.
+func discardConfusingLines(files [2]*lineFile, numClasses int) {
+	var counts [2][]int
+	for f := range files {
+		counts[f] = make([]int, numClasses)
+		for _, e := range files[f].equivs {
+			counts[f][e]++
+		}
+	}
+
+	var discarded [2][]int
+	for f := range files {
+		end := len(files[f].equivs)
+		discards := make([]int, end)
+		otherCounts := counts[1-f]
+		// many is roughly the square root of the number of lines
+		many := 5
+		for tem := end / 64; ; {
+			tem >>= 2
+			if tem <= 0 {
+				break
+			}
+			many *= 2
+		}
+		for i, e := range files[f].equivs {
+			switch nmatch := otherCounts[e]; {
+			case nmatch == 0:
+				discards[i] = 1
+			case nmatch > many:
+				discards[i] = 2
+			}
+		}
+		discarded[f] = discards
+	}
+
+	// Provisional discards are only kept in the middle of a run of discarded lines.
+	for f := range files {
+		discards := discarded[f]
+		end := len(discards)
+		for i := 0; i < end; i++ {
+			if discards[i] == 2 {
+				discards[i] = 0
+				continue
+			}
+			if discards[i] == 0 {
+				continue
+			}
+			j := i
+			provisional := 0
+			for ; j < end && discards[j] != 0; j++ {
+				if discards[j] == 2 {
+					provisional++
+				}
+			}
+			for j > i && discards[j-1] == 2 {
+				j--
+				discards[j] = 0
+				provisional--
+			}
+			length := j - i
+
+			if provisional*4 > length {
+				for j > i {
+					j--
+					if discards[j] == 2 {
+						discards[j] = 0
+					}
+				}
+				continue
+			}
+
+			minimum := 1
+			for tem := length >> 2; ; {
+				tem >>= 2
+				if tem <= 0 {
+					break
+				}
+				minimum <<= 1
+			}
+			minimum++
+
+			consec := 0
+			for j = 0; j < length; j++ {
+				switch {
+				case discards[i+j] != 2:
+					consec = 0
+				case minimum == consec+1:
+					consec++
+					j -= consec
+				default:
+					consec++
+					if minimum < consec {
+						discards[i+j] = 0
+					}
+				}
+			}
+
+			consec = 0
+			for j = 0; j < length; j++ {
+				if j >= 8 && discards[i+j] == 1 {
+					break
+				}
+				switch discards[i+j] {
+				case 2:
+					consec = 0
+					discards[i+j] = 0
+				case 0:
+					consec = 0
+				default:
+					consec++
+				}
+				if consec == 3 {
+					break
+				}
+			}
+
+			i += length - 1
+
+			consec = 0
+			for j = 0; j < length; j++ {
+				if j >= 8 && discards[i-j] == 1 {
+					break
+				}
+				switch discards[i-j] {
+				case 2:
+					consec = 0
+					discards[i-j] = 0
+				case 0:
+					consec = 0
+				default:
+					consec++
+				}
+				if consec == 3 {
+					break
+				}
+			}
+		}
+	}
+
+	for f, file := range files {
+		for i, e := range file.equivs {
+			if discarded[f][i] == 0 {
+				file.undiscarded = append(file.undiscarded, e)
+				file.realIndexes = append(file.realIndexes, i)
+			} else {
+				file.setChanged(i, true)
+			}
+		}
+	}
+}
+
+// lineSearch finds the shortest edit script between the undiscarded lines of x and y
+// by recursively splitting the problem at the middle snake.
+type lineSearch struct {
+	x, y   *lineFile
+	fd, bd []int
+}
+
+func (s *lineSearch) compare(xoff, xlim, yoff, ylim int) {
+	xv, yv := s.x.undiscarded, s.y.undiscarded
+	for xoff < xlim && yoff < ylim && xv[xoff] == yv[yoff] {
+		xoff++
+		yoff++
+	}
+	for xoff < xlim && yoff < ylim && xv[xlim-1] == yv[ylim-1] {
+		xlim--
+		ylim--
+	}
+
+	switch {
+	case xoff == xlim:
+		for ; yoff < ylim; yoff++ {
+			s.y.setChanged(s.y.realIndexes[yoff], true)
+		}
+	case yoff == ylim:
+		for ; xoff < xlim; xoff++ {
+			s.x.setChanged(s.x.realIndexes[xoff], true)
+		}
+	default:
+		xmid, ymid := s.middleSnake(xoff, xlim, yoff, ylim)
+		s.compare(xoff, xmid, yoff, ymid)
+		s.compare(xmid, xlim, ymid, ylim)
+	}
+}
+
+func (s *lineSearch) middleSnake(xoff, xlim, yoff, ylim int) (int, int) {
+	xv, yv := s.x.undiscarded, s.y.undiscarded
+	if s.fd == nil {
+		size := len(xv) + len(yv) + 3
+		s.fd = make([]int, size)
+		s.bd = make([]int, size)
+	}
+	// Diagonals range from -len(yv)-1 up to len(xv)+1
+	offset := len(yv) + 1
+	fd := func(d int) *int { return &s.fd[d+offset] }
+	bd := func(d int) *int { return &s.bd[d+offset] }
+
+	dmin, dmax := xoff-ylim, xlim-yoff
+	fmid, bmid := xoff-yoff, xlim-ylim
+	fmin, fmax := fmid, fmid
+	bmin, bmax := bmid, bmid
+	odd := (fmid-bmid)&1 != 0
+
+	*fd(fmid) = xoff
+	*bd(bmid) = xlim
+
+	for {
+		if fmin > dmin {
+			fmin--
+			*fd(fmin - 1) = -1
+		} else {
+			fmin++
+		}
+		if fmax < dmax {
+			fmax++
+			*fd(fmax + 1) = -1
+		} else {
+			fmax--
+		}
+		for d := fmax; d >= fmin; d -= 2 {
+			tlo, thi := *fd(d - 1), *fd(d + 1)
+			x0 := tlo + 1
+			if tlo < thi {
+				x0 = thi
+			}
+			x, y := x0, x0-d
+			for x < xlim && y < ylim && xv[x] == yv[y] {
+				x++
+				y++
+			}
+			*fd(d) = x
+			if odd && bmin <= d && d <= bmax && *bd(d) <= x {
+				return x, y
+			}
+		}
+
+		if bmin > dmin {
+			bmin--
+			*bd(bmin - 1) = int(^uint(0) >> 1)
+		} else {
+			bmin++
+		}
+		if bmax < dmax {
+			bmax++
+			*bd(bmax + 1) = int(^uint(0) >> 1)
+		} else {
+			bmax--
+		}
+		for d := bmax; d >= bmin; d -= 2 {
+			tlo, thi := *bd(d - 1), *bd(d + 1)
+			x0 := thi - 1
+			if tlo < thi {
+				x0 = tlo
+			}
+			x, y := x0, x0-d
+			for xoff < x && yoff < y && xv[x-1] == yv[y-1] {
+				x--
+				y--
+			}
+			*bd(d) = x
+			if !odd && fmin <= d && d <= fmax && x <= *fd(d) {
+				return x, y
+			}
+		}
+	}
+}
+
+// shiftBoundaries slides each run of changed lines so that runs merge where possible, are placed as late
+// as possible and line up with the changes in the other file.
+func shiftBoundaries(files [2]*lineFile) {
+	for f := range files {
+		file := files[f]
+		other := files[1-f]
+		equivs := file.equivs
+		iEnd := len(equivs)
+		i, j := 0, 0
+
+		for {
+			for i < iEnd && !file.isChanged(i) {
+				for other.isChanged(j) {
+					j++
+				}
+				j++
+				i++
+			}
+			if i == iEnd {
+				break
+			}
+			start := i
+
+			for i++; file.isChanged(i); i++ {
+			}
+			for other.isChanged(j) {
+				j++
+			}
+
+			var corresponding int
+			for {
+				runLength := i - start
+
+				for start > 0 && equivs[start-1] == equivs[i-1] {
+					start--
+					file.setChanged(start, true)
+					i--
+					file.setChanged(i, false)
+					for file.isChanged(start - 1) {
+						start--
+					}
+					for j--; other.isChanged(j); j-- {
+					}
+				}
+
+				corresponding = iEnd
+				if other.isChanged(j - 1) {
+					corresponding = i
+				}
+
+				for i != iEnd && equivs[start] == equivs[i] {
+					file.setChanged(start, false)
+					start++
+					file.setChanged(i, true)
+					i++
+					for file.isChanged(i) {
+						i++
+					}
+					for j++; other.isChanged(j); j++ {
+						corresponding = i
+					}
+				}
+
+				if runLength == i-start {
+					break
+				}
+			}
+
+			for corresponding < i {
+				start--
+				file.setChanged(start, true)
+				i--
+				file.setChanged(i, false)
+				for j--; other.isChanged(j); j-- {
+				}
+			}
+		}
+	}
+}
+
+// lineChange is a block of deleted lines in the first file replaced by inserted lines in the second.
+type lineChange struct {
+	line0, line1      int
+	deleted, inserted int
+}
+
+type hunk []lineChange
+
+// findHunks groups the changes into hunks, changes whose context would overlap share a hunk.
+func findHunks(changedA, changedB []bool) []hunk {
+	changes := make([]lineChange, 0)
+	for i0, i1 := 0, 0; i0 < len(changedA) || i1 < len(changedB); {
+		if (i0 < len(changedA) && changedA[i0]) || (i1 < len(changedB) && changedB[i1]) {
+			c := lineChange{line0: i0, line1: i1}
+			for i0 < len(changedA) && changedA[i0] {
+				i0++
+			}
+			for i1 < len(changedB) && changedB[i1] {
+				i1++
+			}
+			c.deleted = i0 - c.line0
+			c.inserted = i1 - c.line1
+			changes = append(changes, c)
+			continue
+		}
+		i0++
+		i1++
+	}
+
+	hunks := make([]hunk, 0)
+	for i := 0; i < len(changes); {
+		j := i + 1
+		for ; j < len(changes); j++ {
+			top0 := changes[j-1].line0 + changes[j-1].deleted
+			if changes[j].line0-top0 >= 2*unifiedContext+1 {
+				break
+			}
+		}
+		hunks = append(hunks, changes[i:j])
+		i = j
+	}
+	return hunks
+}
+
+func (h hunk) write(buf *bytes.Buffer, a, b []string) {
+	first, last := h[0], h[len(h)-1]
+	first0 := max(first.line0-unifiedContext, 0)
+	first1 := max(first.line1-unifiedContext, 0)
+	last0 := min(last.line0+last.deleted-1+unifiedContext, len(a)-1)
+	last1 := min(last.line1+last.inserted-1+unifiedContext, len(b)-1)
+
+	fmt.Fprintf(buf, "@@ -%s +%s @@\n", unifiedRange(first0, last0), unifiedRange(first1, last1))
+
+	i, j := first0, first1
+	next := 0
+	for i <= last0 || j <= last1 {
+		if next >= len(h) || i < h[next].line0 {
+			writeLine(buf, ' ', a[i])
+			i++
+			j++
+			continue
+		}
+		for k := 0; k < h[next].deleted; k++ {
+			writeLine(buf, '-', a[i])
+			i++
+		}
+		for k := 0; k < h[next].inserted; k++ {
+			writeLine(buf, '+', b[j])
+			j++
+		}
+		next++
+	}
+}
+
+// unifiedRange formats the zero based inclusive range [start, end] the way diff prints hunk ranges.
+func unifiedRange(start, end int) string {
+	switch {
+	case end < start:
+		return fmt.Sprintf("%d,0", end+1)
+	case end == start:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, end-start+1)
+}
+
+func writeLine(buf *bytes.Buffer, prefix byte, line string) {
+	buf.WriteByte(prefix)
+	buf.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		buf.WriteString("\n\\ No newline at end of file\n")
+	}
+}
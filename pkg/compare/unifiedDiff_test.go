@@ -0,0 +1,78 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	header := "diff -u -N a b\n--- a\t2024-01-01 00:00:00.000000000 +0000\n+++ b\t2024-01-01 00:00:00.000000000 +0000\n"
+	tests := []struct {
+		name     string
+		from     []string
+		to       []string
+		expected []string
+	}{
+		{
+			name: "identical",
+			from: []string{"a", "b"},
+			to:   []string{"a", "b"},
+		},
+		{
+			name:     "changed line",
+			from:     []string{"a", "b", "c"},
+			to:       []string{"a", "x", "c"},
+			expected: []string{"@@ -1,3 +1,3 @@", " a", "-b", "+x", " c"},
+		},
+		{
+			name:     "added to empty",
+			to:       []string{"a"},
+			expected: []string{"@@ -0,0 +1 @@", "+a"},
+		},
+		{
+			name:     "removed all",
+			from:     []string{"a", "b"},
+			expected: []string{"@@ -1,2 +0,0 @@", "-a", "-b"},
+		},
+		{
+			name:     "distant changes use separate hunks",
+			from:     []string{"a", "1", "2", "3", "4", "5", "6", "7", "b"},
+			to:       []string{"x", "1", "2", "3", "4", "5", "6", "7", "y"},
+			expected: []string{"@@ -1,4 +1,4 @@", "-a", "+x", " 1", " 2", " 3", "@@ -6,4 +6,4 @@", " 5", " 6", " 7", "-b", "+y"},
+		},
+		{
+			name:     "close changes share a hunk",
+			from:     []string{"a", "1", "2", "3", "4", "5", "6", "b"},
+			to:       []string{"x", "1", "2", "3", "4", "5", "6", "y"},
+			expected: []string{"@@ -1,8 +1,8 @@", "-a", "+x", " 1", " 2", " 3", " 4", " 5", " 6", "-b", "+y"},
+		},
+		{
+			name:     "insertion is placed after repeated lines",
+			from:     []string{"- a", "- a", "b"},
+			to:       []string{"- a", "- a", "- a", "b"},
+			expected: []string{"@@ -1,3 +1,4 @@", " - a", " - a", "+- a", " b"},
+		},
+	}
+	lines := func(l []string) []byte {
+		if len(l) == 0 {
+			return nil
+		}
+		return []byte(strings.Join(l, "\n") + "\n")
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("Diff %s", test.name), func(t *testing.T) {
+			expected := ""
+			if len(test.expected) > 0 {
+				expected = header + string(lines(test.expected))
+			}
+			assert.Equal(t, expected, unifiedDiff("a", "b", lines(test.from), lines(test.to), date))
+		})
+	}
+}
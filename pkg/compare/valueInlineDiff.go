@@ -0,0 +1,180 @@
+package compare
+
+import (
+	"fmt"
+)
+
+const (
+	regexList            inlineDiffType = "regexList"
+	capturegroupsList    inlineDiffType = "capturegroupsList"
+	quantityList         inlineDiffType = "quantityList"
+	numericRangeList     inlineDiffType = "numericRangeList"
+	semverConstraintList inlineDiffType = "semverConstraintList"
+	cidrContainsList     inlineDiffType = "cidrContainsList"
+	ipInCIDRList         inlineDiffType = "ipInCIDRList"
+)
+
+// StringInlineDiff is implemented by inline diff functions that work on the text of a field.
+type StringInlineDiff interface {
+	Diff(templateValue, crValue string) string
+	Validate(templateValue string) error
+}
+
+// capturingInlineDiff is implemented by inline diff functions with named capture groups. The groups are
+// captured from every field of a CR before any of them is diffed, so each group holds one value per CR.
+type capturingInlineDiff interface {
+	Capture(templateValue, crValue any) captures
+	DiffWithCaptures(templateValue, crValue any, caps captures) any
+}
+
+// stringCapturingInlineDiff is the text form of capturingInlineDiff
+type stringCapturingInlineDiff interface {
+	Capture(templateValue, crValue string) captures
+	DiffWithCaptures(templateValue, crValue string, caps captures) string
+}
+
+// stringValues runs a StringInlineDiff on string fields, numbers and booleans are passed in their text form
+// so that for example a numericRange can be used on an integer field.
+type stringValues struct {
+	StringInlineDiff
+}
+
+func scalarString(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case int, int32, int64, float32, float64, bool:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+func (id stringValues) Diff(templateValue, crValue any) any {
+	return id.diffText(templateValue, crValue, id.StringInlineDiff.Diff)
+}
+
+func (id stringValues) Capture(templateValue, crValue any) captures {
+	capturing, ok := id.StringInlineDiff.(stringCapturingInlineDiff)
+	if !ok {
+		return nil
+	}
+	templateText, ok := scalarString(templateValue)
+	if !ok {
+		return nil
+	}
+	crText, ok := scalarString(crValue)
+	if !ok {
+		return nil
+	}
+	return capturing.Capture(templateText, crText)
+}
+
+func (id stringValues) DiffWithCaptures(templateValue, crValue any, caps captures) any {
+	capturing, ok := id.StringInlineDiff.(stringCapturingInlineDiff)
+	if !ok {
+		return id.Diff(templateValue, crValue)
+	}
+	return id.diffText(templateValue, crValue, func(templateText, crText string) string {
+		return capturing.DiffWithCaptures(templateText, crText, caps)
+	})
+}
+
+func (id stringValues) diffText(templateValue, crValue any, diff func(templateText, crText string) string) any {
+	templateText, ok := scalarString(templateValue)
+	if !ok {
+		return templateValue
+	}
+	crText, ok := scalarString(crValue)
+	if !ok {
+		return templateValue
+	}
+	// Return the values as they were when the function keeps them so their type doesn't show up as a diff
+	switch result := diff(templateText, crText); result {
+	case crText:
+		return crValue
+	case templateText:
+		return templateValue
+	default:
+		return result
+	}
+}
+
+func (id stringValues) Validate(templateValue any) error {
+	templateText, ok := scalarString(templateValue)
+	if !ok {
+		return fmt.Errorf("expected a string, number or boolean value but got %T", templateValue)
+	}
+	return id.StringInlineDiff.Validate(templateText) // nolint:wrapcheck
+}
+
+// listValues runs an inline diff function on each item of a list, the items of the template
+// are compared with the items of the cluster CR in the same position.
+type listValues struct {
+	InlineDiff
+}
+
+func (id listValues) Diff(templateValue, crValue any) any {
+	return id.diffItems(templateValue, crValue, id.InlineDiff.Diff)
+}
+
+func (id listValues) Capture(templateValue, crValue any) captures {
+	capturing, ok := id.InlineDiff.(capturingInlineDiff)
+	if !ok {
+		return nil
+	}
+	templateList, ok := templateValue.([]any)
+	if !ok {
+		return nil
+	}
+	crList, ok := crValue.([]any)
+	if !ok {
+		return nil
+	}
+	result := make(captures)
+	for i := 0; i < min(len(templateList), len(crList)); i++ {
+		result.merge(capturing.Capture(templateList[i], crList[i]))
+	}
+	return result
+}
+
+func (id listValues) DiffWithCaptures(templateValue, crValue any, caps captures) any {
+	capturing, ok := id.InlineDiff.(capturingInlineDiff)
+	if !ok {
+		return id.Diff(templateValue, crValue)
+	}
+	return id.diffItems(templateValue, crValue, func(templateItem, crItem any) any {
+		return capturing.DiffWithCaptures(templateItem, crItem, caps)
+	})
+}
+
+func (id listValues) diffItems(templateValue, crValue any, diff func(templateItem, crItem any) any) any {
+	templateList, ok := templateValue.([]any)
+	if !ok {
+		return templateValue
+	}
+	crList, ok := crValue.([]any)
+	if !ok {
+		return templateValue
+	}
+	result := make([]any, 0, len(templateList))
+	for i, item := range templateList {
+		if i < len(crList) {
+			item = diff(item, crList[i])
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+func (id listValues) Validate(templateValue any) error {
+	templateList, ok := templateValue.([]any)
+	if !ok {
+		return fmt.Errorf("expected a list but got %T", templateValue)
+	}
+	for i, item := range templateList {
+		if err := id.InlineDiff.Validate(item); err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueInlineDiffs(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       inlineDiffType
+		template any
+		cr       any
+		expected any
+	}{
+		{"regex on a string", regex, "a[0-9]", "a1", "a1"},
+		{"numericRange keeps the type of the cr value", numericRange, "1..5", int64(3), int64(3)},
+		{"numericRange outside of range", numericRange, "1..5", int64(6), "1..5"},
+		{"quantity on a number", quantity, "2000m", float64(2), float64(2)},
+		{"regex on a map", regex, ".*", map[string]any{"a": "b"}, ".*"},
+		{
+			"regexList",
+			regexList,
+			[]any{"10\\.0\\.0\\.[0-9]+", "10\\.0\\.1\\.[0-9]+"},
+			[]any{"10.0.0.5", "10.0.2.7"},
+			[]any{"10.0.0.5", "10\\.0\\.1\\.[0-9]+"},
+		},
+		{"regexList with missing item", regexList, []any{"a", "b"}, []any{"a"}, []any{"a", "b"}},
+		{"subsetOf list", subsetOf, []any{"a", "c"}, []any{"c", "b", "a"}, []any{"c", "b", "a"}},
+		{"subsetOf list missing item", subsetOf, []any{"a", "d"}, []any{"c", "a"}, []any{"c", "a", "d"}},
+		{"subsetOf list duplicated item", subsetOf, []any{"a", "a"}, []any{"a"}, []any{"a", "a"}},
+		{
+			"subsetOf map",
+			subsetOf,
+			map[string]any{"a": "1"},
+			map[string]any{"a": "1", "b": "2"},
+			map[string]any{"a": "1", "b": "2"},
+		},
+		{
+			"subsetOf map with different value",
+			subsetOf,
+			map[string]any{"a": "1"},
+			map[string]any{"a": "2", "b": "2"},
+			map[string]any{"a": "1", "b": "2"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, InlineDiffs[test.fn].Diff(test.template, test.cr))
+		})
+	}
+}
+
+func TestValueInlineDiffsValidate(t *testing.T) {
+	assert.NoError(t, InlineDiffs[numericRange].Validate("1..5"))
+	assert.Error(t, InlineDiffs[regex].Validate([]any{"a"}))
+	assert.NoError(t, InlineDiffs[regexList].Validate([]any{"a", "b+"}))
+	assert.ErrorContains(t, InlineDiffs[regexList].Validate([]any{"a", "b("}), "item 1")
+	assert.Error(t, InlineDiffs[regexList].Validate("a"))
+	assert.NoError(t, InlineDiffs[subsetOf].Validate(map[string]any{}))
+	assert.Error(t, InlineDiffs[subsetOf].Validate("a"))
+}
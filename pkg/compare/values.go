@@ -0,0 +1,113 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// ValuesKey is the key the values of the reference parameters are exposed under to templates, next to the fields
+// of the cluster CR
+const ValuesKey = "Values"
+
+const (
+	valuesFileNotExistsError = "values file %s doesn't exist"
+	valuesFileNotInFormat    = "values file %s isn't a yaml map: %w"
+)
+
+// LoadValues returns the values of the reference parameters: the values in the values file, when one is given,
+// merged over the default values of the reference. The values are validated against the values schema of the
+// reference so mistakes are caught before any CR is compared.
+func LoadValues(ref Reference, valuesFile string) (map[string]any, error) {
+	values := make(map[string]any)
+	if defaults := ref.GetValues(); defaults != nil {
+		values = runtime.DeepCopyJSON(defaults)
+	}
+	if valuesFile != "" {
+		content, err := os.ReadFile(valuesFile)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf(valuesFileNotExistsError, valuesFile)
+			}
+			return nil, fmt.Errorf("failed to read values file %s: %w", valuesFile, err)
+		}
+		fileValues := make(map[string]any)
+		if err := yaml.Unmarshal(content, &fileValues); err != nil {
+			return nil, fmt.Errorf(valuesFileNotInFormat, valuesFile, err)
+		}
+		coalesceValues(values, fileValues)
+	}
+	if err := validateValues(values, ref.GetValuesSchema()); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// coalesceValues merges src into dst like Helm merges values files: maps are merged key by key and any other value
+// in src replaces the value in dst.
+func coalesceValues(dst, src map[string]any) {
+	for key, value := range src {
+		srcMap, srcIsMap := value.(map[string]any)
+		dstMap, dstIsMap := dst[key].(map[string]any)
+		if srcIsMap && dstIsMap {
+			coalesceValues(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+// compileValuesSchema checks that the values schema of a reference is a valid JSON schema
+func compileValuesSchema(schema map[string]any) (*gojsonschema.Schema, error) {
+	compiled, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("reference contains an invalid valuesSchema: %w", err)
+	}
+	return compiled, nil
+}
+
+func validateValues(values, schema map[string]any) error {
+	if schema == nil {
+		return nil
+	}
+	compiled, err := compileValuesSchema(schema)
+	if err != nil {
+		return err
+	}
+	result, err := compiled.Validate(gojsonschema.NewGoLoader(values))
+	if err != nil {
+		return fmt.Errorf("failed to validate the values against the valuesSchema of the reference: %w", err)
+	}
+	if result.Valid() {
+		return nil
+	}
+	issues := make([]string, 0, len(result.Errors()))
+	for _, issue := range result.Errors() {
+		issues = append(issues, fmt.Sprintf("- %s: %s", issue.Field(), issue.Description()))
+	}
+	sort.Strings(issues)
+	return errors.New("the values don't match the valuesSchema of the reference:\n" + strings.Join(issues, "\n"))
+}
+
+// templateData returns the data templates are executed with, the fields of the CR and the values of the reference
+// under ValuesKey. Templates are executed concurrently and functions such as set and merge change the values, so
+// every execution gets its own copy of them.
+func templateData(cr, values map[string]any) map[string]any {
+	data := make(map[string]any, len(cr)+1)
+	for key, value := range cr {
+		data[key] = value
+	}
+	if values != nil {
+		values = runtime.DeepCopyJSON(values)
+	}
+	data[ValuesKey] = values
+	return data
+}
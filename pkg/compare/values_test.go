@@ -0,0 +1,27 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateDataCopiesValues(t *testing.T) {
+	values := map[string]any{"network": map[string]any{"mtu": int64(1500)}}
+	parsed, err := template.New("values.yaml").Funcs(FuncMap()).Parse(
+		`{{ .Values.network.mtu }}{{ $_ := set .Values.network "mtu" 9000 }}`)
+	require.NoError(t, err)
+
+	for range 2 {
+		var out bytes.Buffer
+		require.NoError(t, parsed.Execute(&out, templateData(map[string]any{}, values)))
+		assert.Equal(t, "1500", out.String(), "values set by an execution aren't seen by the next ones")
+	}
+	assert.Equal(t, map[string]any{"network": map[string]any{"mtu": int64(1500)}}, values)
+	assert.Nil(t, templateData(map[string]any{}, nil)[ValuesKey])
+}
@@ -40,6 +40,9 @@ func RemoveInconsistentInfo(t *testing.T, text string) string {
 	// remove diff tool generated temp directory path
 	re := getTempRegex(t)
 	text = re.ReplaceAllString(text, "TEMP")
+	// remove the built-in diff engine file labels
+	re = regexp.MustCompile(`\b(?:LIVE|MERGED)/`)
+	text = re.ReplaceAllString(text, "TEMP/")
 	// remove diff datetime
 	re = regexp.MustCompile(`(\d{4}-\d{2}-\d{2}\s*\d{2}:\d{2}:\d{2}(:?\.\d{9} [+-]\d{4})?)`)
 	text = re.ReplaceAllString(text, "DATE")